@@ -3,6 +3,8 @@ package authenticator
 import (
 	"context"
 	"net/http"
+
+	"golang.org/x/oauth2"
 )
 
 type AuthResult struct {
@@ -13,4 +15,11 @@ type AuthResult struct {
 type Authenticator interface {
 	Authenticate(context.Context) (*AuthResult, error)
 	GetAuthenticatedClient() *http.Client
+	// GetAuthenticatedClientWithBase returns a client sending authenticated requests using the given round tripper.
+	GetAuthenticatedClientWithBase(base http.RoundTripper) *http.Client
+	// DeviceAuthorization returns the device authorization that is waiting to be completed by the user, if any.
+	DeviceAuthorization() *oauth2.DeviceAuthResponse
+	// UserId returns the Netcup SCP user id taken from the claims of the current access token.
+	// It returns ErrNoUserIdInToken if the token does not carry one.
+	UserId() (int32, error)
 }
@@ -3,8 +3,10 @@ package authenticator
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"sync"
 
 	"golang.org/x/oauth2"
 )
@@ -18,8 +20,12 @@ var (
 )
 
 type DefaultAuthenticator struct {
+	mu                  sync.Mutex
 	refreshToken        *string
+	tokenStore          TokenStore
+	deviceAuth          *oauth2.DeviceAuthResponse
 	authenticatedClient *http.Client
+	tokenSource         oauth2.TokenSource
 	clientId            string
 	scopes              []string
 }
@@ -28,9 +34,12 @@ var _ Authenticator = &DefaultAuthenticator{}
 
 // NewDefaultAuthenticator creates a new DefaultAuthenticator with the given refresh token.
 // Refresh token can be empty, in which case new device authorization flow will be used.
-func NewDefaultAuthenticator(refreshToken string) *DefaultAuthenticator {
+// Token store is optional. If set, a stored refresh token takes precedence over the given one
+// and every rotated refresh token is written back to it.
+func NewDefaultAuthenticator(refreshToken string, tokenStore TokenStore) *DefaultAuthenticator {
 	return &DefaultAuthenticator{
 		refreshToken: &refreshToken,
+		tokenStore:   tokenStore,
 		clientId:     netcupClientId,
 		scopes:       netcupScopes,
 	}
@@ -49,52 +58,111 @@ func (a *DefaultAuthenticator) createOAuthConfig() (*oauth2.Config, error) {
 	return config, nil
 }
 
-func (a *DefaultAuthenticator) newDeviceAuth(ctx context.Context, oauthConfig *oauth2.Config) (string, error) {
+func (a *DefaultAuthenticator) newDeviceAuth(ctx context.Context, oauthConfig *oauth2.Config) (*oauth2.Token, error) {
 	deviceAuth, err := oauthConfig.DeviceAuth(ctx)
 	if err != nil {
 		slog.Error("error during device authorization", "error", err)
-		return "", err
+		return nil, err
 	}
 	slog.Info("complete device authorization using given uri and code", "verification_uri", deviceAuth.VerificationURI, "user_code", deviceAuth.UserCode)
+	a.setDeviceAuthorization(deviceAuth)
+	defer a.setDeviceAuthorization(nil)
 	token, err := oauthConfig.DeviceAccessToken(ctx, deviceAuth)
 	if err != nil {
 		slog.Error("error getting access token", "error", err)
-		return "", err
+		return nil, err
 	}
 	if token.AccessToken == "" || token.RefreshToken == "" {
 		slog.Error("received empty access token or refresh token during device authorization")
-		return "", errors.New("received empty access token or refresh token during device authorization")
+		return nil, errors.New("received empty access token or refresh token during device authorization")
 	}
 	slog.Debug("successfully obtained access token and refresh token via device authorization")
-	return token.RefreshToken, nil
+	return token, nil
 }
 
 func (a *DefaultAuthenticator) refreshTokenAuth(ctx context.Context, oauthConfig *oauth2.Config) error {
 	token := &oauth2.Token{
 		RefreshToken: *a.refreshToken,
 	}
-	client := oauthConfig.Client(ctx, token)
-	a.authenticatedClient = client
+	a.tokenAuth(ctx, oauthConfig, token)
 	slog.Debug("successfully obtained authenticated client using refresh token")
 	return nil
 }
 
+// tokenAuth creates the authenticated client from the given token. The client refreshes the token
+// on its own and writes every rotated refresh token back to the token store.
+func (a *DefaultAuthenticator) tokenAuth(ctx context.Context, oauthConfig *oauth2.Config, token *oauth2.Token) {
+	tokenSource := &storingTokenSource{
+		source:       oauthConfig.TokenSource(ctx, token),
+		onRotate:     a.storeRefreshToken,
+		refreshToken: token.RefreshToken,
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.tokenSource = oauth2.ReuseTokenSource(token, tokenSource)
+	a.authenticatedClient = oauth2.NewClient(ctx, a.tokenSource)
+}
+
+func (a *DefaultAuthenticator) setDeviceAuthorization(deviceAuth *oauth2.DeviceAuthResponse) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.deviceAuth = deviceAuth
+}
+
+// loadRefreshToken replaces the configured refresh token with the stored one, if there is any.
+func (a *DefaultAuthenticator) loadRefreshToken() error {
+	if a.tokenStore == nil {
+		return nil
+	}
+	storedToken, err := a.tokenStore.Load()
+	if err != nil {
+		slog.Error("error loading refresh token from token store", "error", err)
+		return err
+	}
+	if storedToken != "" {
+		slog.Debug("using refresh token from token store")
+		a.refreshToken = &storedToken
+	}
+	return nil
+}
+
+// storeRefreshToken remembers the given refresh token and writes it to the token store, if there is any.
+func (a *DefaultAuthenticator) storeRefreshToken(refreshToken string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.refreshToken = &refreshToken
+	if a.tokenStore == nil {
+		return
+	}
+	if err := a.tokenStore.Save(refreshToken); err != nil {
+		slog.Error("error saving refresh token to token store", "error", err)
+		return
+	}
+	slog.Debug("saved refresh token to token store")
+}
+
 func (a *DefaultAuthenticator) Authenticate(ctx context.Context) (*AuthResult, error) {
 	oauthConfig, err := a.createOAuthConfig()
 	if err != nil {
 		return nil, err
 	}
 
+	if err := a.loadRefreshToken(); err != nil {
+		return nil, err
+	}
+
 	// If refresh token is empty, use new device authorization flow.
 	if a.refreshToken == nil || *a.refreshToken == "" {
-		refreshToken, err := a.newDeviceAuth(ctx, oauthConfig)
+		token, err := a.newDeviceAuth(ctx, oauthConfig)
 		if err != nil {
 			return nil, err
 		}
+		a.storeRefreshToken(token.RefreshToken)
+		a.tokenAuth(ctx, oauthConfig, token)
 
 		return &AuthResult{
 			IsNewDevice:  true,
-			RefreshToken: refreshToken,
+			RefreshToken: token.RefreshToken,
 		}, nil
 	}
 	// Otherwise, use refresh token flow for existing device.
@@ -109,5 +177,45 @@ func (a *DefaultAuthenticator) Authenticate(ctx context.Context) (*AuthResult, e
 }
 
 func (a *DefaultAuthenticator) GetAuthenticatedClient() *http.Client {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 	return a.authenticatedClient
 }
+
+// GetAuthenticatedClientWithBase returns a client sending authenticated requests using the given round tripper.
+// Requests refreshing the access token do not pass it. Nil is returned if not authenticated yet.
+func (a *DefaultAuthenticator) GetAuthenticatedClientWithBase(base http.RoundTripper) *http.Client {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.authenticatedClient == nil {
+		return nil
+	}
+	return &http.Client{
+		Transport: &oauth2.Transport{Source: a.tokenSource, Base: base},
+		Timeout:   a.authenticatedClient.Timeout,
+	}
+}
+
+func (a *DefaultAuthenticator) DeviceAuthorization() *oauth2.DeviceAuthResponse {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.deviceAuth
+}
+
+func (a *DefaultAuthenticator) UserId() (int32, error) {
+	a.mu.Lock()
+	tokenSource := a.tokenSource
+	a.mu.Unlock()
+	if tokenSource == nil {
+		return 0, errors.New("not authenticated yet")
+	}
+	token, err := tokenSource.Token()
+	if err != nil {
+		return 0, err
+	}
+	userId, err := userIdFromAccessToken(token.AccessToken)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrNoUserIdInToken, err)
+	}
+	return userId, nil
+}
@@ -0,0 +1,57 @@
+package authenticator
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const tokenFilePerm fs.FileMode = 0600
+
+// FileTokenStore stores the refresh token in a file that is only readable and writable by the owner.
+type FileTokenStore struct {
+	path string
+}
+
+var _ TokenStore = FileTokenStore{}
+
+func NewFileTokenStore(path string) FileTokenStore {
+	return FileTokenStore{
+		path: path,
+	}
+}
+
+func (s FileTokenStore) Load() (string, error) {
+	content, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// Save writes the refresh token to a temporary file first and renames it afterwards,
+// so that a crash while writing never leaves a truncated token behind.
+func (s FileTokenStore) Save(refreshToken string) error {
+	tmpFile, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if err := tmpFile.Chmod(tokenFilePerm); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if _, err := tmpFile.WriteString(refreshToken + "\n"); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), s.path)
+}
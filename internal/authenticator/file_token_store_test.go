@@ -0,0 +1,49 @@
+package authenticator
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestFileTokenStoreLoadMissingFile(t *testing.T) {
+	store := NewFileTokenStore(filepath.Join(t.TempDir(), "token"))
+
+	got, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v; want nil", err)
+	}
+	if got != "" {
+		t.Errorf("Load() = %q; want empty string", got)
+	}
+}
+
+func TestFileTokenStoreSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	store := NewFileTokenStore(path)
+
+	for _, token := range []string{"first-token", "rotated-token"} {
+		if err := store.Save(token); err != nil {
+			t.Fatalf("Save(%q) error = %v; want nil", token, err)
+		}
+		got, err := store.Load()
+		if err != nil {
+			t.Fatalf("Load() error = %v; want nil", err)
+		}
+		if got != token {
+			t.Errorf("Load() = %q; want %q", got, token)
+		}
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat() error = %v; want nil", err)
+	}
+	if perm := info.Mode().Perm(); perm != tokenFilePerm {
+		t.Errorf("token file permissions = %v; want %v", perm, tokenFilePerm)
+	}
+}
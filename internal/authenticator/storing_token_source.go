@@ -0,0 +1,32 @@
+package authenticator
+
+import (
+	"log/slog"
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+// storingTokenSource wraps a token source and calls onRotate whenever the
+// token endpoint hands out a new refresh token.
+type storingTokenSource struct {
+	source       oauth2.TokenSource
+	onRotate     func(refreshToken string)
+	mu           sync.Mutex
+	refreshToken string
+}
+
+func (s *storingTokenSource) Token() (*oauth2.Token, error) {
+	token, err := s.source.Token()
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if token.RefreshToken != "" && token.RefreshToken != s.refreshToken {
+		slog.Debug("refresh token has been rotated")
+		s.refreshToken = token.RefreshToken
+		s.onRotate(token.RefreshToken)
+	}
+	return token, nil
+}
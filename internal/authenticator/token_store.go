@@ -0,0 +1,9 @@
+package authenticator
+
+// TokenStore persists the refresh token, so that rotated refresh tokens survive restarts.
+type TokenStore interface {
+	// Load returns the stored refresh token or an empty string if no token has been stored yet.
+	Load() (string, error)
+	// Save stores the given refresh token, replacing any previously stored token.
+	Save(refreshToken string) error
+}
@@ -0,0 +1,55 @@
+package authenticator
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrNoUserIdInToken is returned if the access token does not carry a user id, which does not change
+// with refreshing the token.
+var ErrNoUserIdInToken = errors.New("access token contains no user id")
+
+// userIdClaims are the claims of an access token that may carry the numeric Netcup SCP user id, in order of preference.
+var userIdClaims = []string{"id", "user_id", "sub"}
+
+// userIdFromAccessToken reads the user id from the claims of the given JWT access token.
+// The token is only decoded, not verified, as it has been obtained from the token endpoint directly.
+func userIdFromAccessToken(accessToken string) (int32, error) {
+	parts := strings.Split(accessToken, ".")
+	if len(parts) != 3 {
+		return 0, errors.New("access token is not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return 0, fmt.Errorf("unable to decode access token payload: %w", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	var claims map[string]any
+	if err := decoder.Decode(&claims); err != nil {
+		return 0, fmt.Errorf("unable to parse access token claims: %w", err)
+	}
+	for _, claim := range userIdClaims {
+		var value string
+		switch v := claims[claim].(type) {
+		case json.Number:
+			value = v.String()
+		case string:
+			value = v
+		default:
+			continue
+		}
+		// The subject usually is a uuid, so only numeric values are taken.
+		userId, err := strconv.ParseInt(value, 10, 32)
+		if err != nil || userId <= 0 {
+			continue
+		}
+		return int32(userId), nil
+	}
+	return 0, errors.New("access token contains no numeric user id")
+}
@@ -0,0 +1,39 @@
+package authenticator
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestUserIdFromAccessToken(t *testing.T) {
+	jwt := func(payload string) string {
+		return "eyJhbGciOiJSUzI1NiJ9." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".c2lnbmF0dXJl"
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		want    int32
+		wantErr bool
+	}{
+		{"id claim", jwt(`{"id": 12345, "sub": "f1d2"}`), 12345, false},
+		{"user_id claim as string", jwt(`{"user_id": "678"}`), 678, false},
+		{"numeric subject", jwt(`{"sub": "42"}`), 42, false},
+		{"uuid subject", jwt(`{"sub": "1b4e28ba-2fa1-11d2-883f-0016d3cca427"}`), 0, true},
+		{"id takes precedence", jwt(`{"sub": "42", "id": 7}`), 7, false},
+		{"not a jwt", "opaque-token", 0, true},
+		{"invalid payload", "a.!!!.c", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := userIdFromAccessToken(tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("userIdFromAccessToken() error = %v; wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("userIdFromAccessToken() = %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+// Package collectortest provides a fake Netcup SCP API for testing collectors.
+package collectortest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/kodehat/netcupscp-exporter/internal/client"
+)
+
+// basePath is the path of the real API, which the fake API serves below as well as at its root.
+const basePath = "/scp-core"
+
+// API is the data served by a fake Netcup SCP API, which is never under maintenance. Requests for
+// servers, logs, reverse DNS entries or metrics missing in the API fail with an internal server error.
+type API struct {
+	// Servers are listed in their minimal form and served in full by their id.
+	Servers []client.Server
+	// ServerLogs are the logs of the servers by server id, which are paged using limit and offset.
+	ServerLogs map[int32][]client.Log
+	// UserLogs are the logs of the user, which are paged using limit and offset.
+	UserLogs []client.Log
+	// Rdns are the reverse DNS entries by ip address.
+	Rdns map[string]string
+	// Metrics are the raw bodies of the metrics endpoints by server id and endpoint, e.g. "network/packet".
+	Metrics map[int32]map[string][]byte
+
+	serverRequests atomic.Int32
+	logRequests    atomic.Int32
+}
+
+// NewServers returns servers with the given ids, which are named "v" followed by their id.
+func NewServers(ids ...int32) []client.Server {
+	servers := make([]client.Server, len(ids))
+	for i, id := range ids {
+		name := fmt.Sprintf("v%d", id)
+		servers[i] = client.Server{Id: &id, Name: &name}
+	}
+	return servers
+}
+
+// ServerRequests returns the number of single servers requested so far.
+func (a *API) ServerRequests() int32 {
+	return a.serverRequests.Load()
+}
+
+// LogRequests returns the number of log pages requested so far.
+func (a *API) LogRequests() int32 {
+	return a.logRequests.Load()
+}
+
+// NewServer starts a server serving the given API, which is closed at the end of the test.
+func NewServer(t testing.TB, api *API) *httptest.Server {
+	t.Helper()
+	writeJson := func(w http.ResponseWriter, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(v); err != nil {
+			t.Errorf("Encode() error = %v", err)
+		}
+	}
+	writeLogPage := func(w http.ResponseWriter, r *http.Request, logs []client.Log) {
+		api.logRequests.Add(1)
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil {
+			limit = len(logs)
+		}
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		start, end := min(offset, len(logs)), min(offset+limit, len(logs))
+		writeJson(w, logs[start:end])
+	}
+	findServer := func(r *http.Request) (client.Server, bool) {
+		id, _ := strconv.Atoi(r.PathValue("serverId"))
+		for _, server := range api.Servers {
+			if server.Id != nil && *server.Id == int32(id) {
+				return server, true
+			}
+		}
+		return client.Server{}, false
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/ping", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("GET /api/v1/maintenance", func(w http.ResponseWriter, r *http.Request) {
+		writeJson(w, client.Maintenance{})
+	})
+	mux.HandleFunc("GET /api/v1/servers", func(w http.ResponseWriter, r *http.Request) {
+		servers := make([]client.ServerListMinimal, len(api.Servers))
+		for i, server := range api.Servers {
+			servers[i] = client.ServerListMinimal{Id: server.Id, Name: server.Name, Nickname: server.Nickname, Hostname: server.Hostname}
+		}
+		writeJson(w, servers)
+	})
+	mux.HandleFunc("GET /api/v1/servers/{serverId}", func(w http.ResponseWriter, r *http.Request) {
+		api.serverRequests.Add(1)
+		server, ok := findServer(r)
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		writeJson(w, server)
+	})
+	mux.HandleFunc("GET /api/v1/servers/{serverId}/logs", func(w http.ResponseWriter, r *http.Request) {
+		id, _ := strconv.Atoi(r.PathValue("serverId"))
+		logs, ok := api.ServerLogs[int32(id)]
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		writeLogPage(w, r, logs)
+	})
+	mux.HandleFunc("GET /api/v1/users/{userId}/logs", func(w http.ResponseWriter, r *http.Request) {
+		writeLogPage(w, r, api.UserLogs)
+	})
+	mux.HandleFunc("GET /api/v1/rdns/{ipType}/{ip}", func(w http.ResponseWriter, r *http.Request) {
+		ptr, ok := api.Rdns[r.PathValue("ip")]
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		writeJson(w, client.Rdns{Rdns: &ptr})
+	})
+	mux.HandleFunc("GET /api/v1/servers/{serverId}/metrics/{endpoint...}", func(w http.ResponseWriter, r *http.Request) {
+		id, _ := strconv.Atoi(r.PathValue("serverId"))
+		body, ok := api.Metrics[int32(id)][r.PathValue("endpoint")]
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	})
+
+	root := http.NewServeMux()
+	root.Handle(basePath+"/", http.StripPrefix(basePath, mux))
+	root.Handle("/", mux)
+	server := httptest.NewServer(root)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// Doer returns a request doer sending all requests meant for the real API to the given server instead.
+func Doer(server *httptest.Server) client.HttpRequestDoer {
+	target, _ := url.Parse(server.URL)
+	return redirectingDoer{target: target, client: server.Client()}
+}
+
+type redirectingDoer struct {
+	target *url.URL
+	client *http.Client
+}
+
+func (d redirectingDoer) Do(req *http.Request) (*http.Response, error) {
+	req.URL.Scheme = d.target.Scheme
+	req.URL.Host = d.target.Host
+	return d.client.Do(req)
+}
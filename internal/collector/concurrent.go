@@ -0,0 +1,64 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/kodehat/netcupscp-exporter/internal/client"
+)
+
+// forEachServer calls fn for every given server using a bounded number of workers.
+// No further calls are started once the context is done, in which case its error is returned.
+func forEachServer(ctx context.Context, workers int, servers []client.ServerListMinimal, fn func(i int, srv client.ServerListMinimal)) error {
+	var (
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, workers)
+	)
+	for i, srv := range servers {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return ctx.Err()
+		}
+		wg.Go(func() {
+			defer func() { <-sem }()
+			fn(i, srv)
+		})
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// ServerResult is the data fetched for a single server of the server list.
+type ServerResult[T any] struct {
+	Server client.ServerListMinimal
+	Data   T
+	// Err is set if fetching the data failed, Data is the zero value in this case.
+	Err error
+}
+
+// collectPerServer fetches data of every server of the account using a bounded number of workers.
+// A server whose data could not be fetched is returned with its error set.
+func collectPerServer[T any](ctx context.Context, c DefaultServerCollector, name string, fetch func(ctx context.Context, serverId int32) (T, error)) ([]ServerResult[T], error) {
+	servers, err := c.listServers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]ServerResult[T], len(servers))
+	err = forEachServer(ctx, c.workers, servers, func(i int, srv client.ServerListMinimal) {
+		results[i].Server = srv
+		data, err := fetch(ctx, *srv.Id)
+		if err != nil {
+			slog.Error("error getting server data", "data", name, "serverId", *srv.Id, "error", err)
+			results[i].Err = err
+			return
+		}
+		results[i].Data = data
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
@@ -5,32 +5,55 @@ import (
 	"errors"
 	"log/slog"
 	"net/http"
+	"net/netip"
 	"time"
 
-	"github.com/kodehat/netcupscp-exporter/internal/authenticator"
 	"github.com/kodehat/netcupscp-exporter/internal/client"
 )
 
 const scpBaseUrl = "https://www.servercontrolpanel.de/scp-core"
 
+// ErrMaintenanceOngoing is returned while Netcup SCP is under maintenance.
+var ErrMaintenanceOngoing = errors.New("maintenance is currently ongoing")
+
 type DefaultServerCollector struct {
-	client *client.ClientWithResponses
+	client  *client.ClientWithResponses
+	workers int
+	// userId resolves the id of the user, which is required for user specific data.
+	userId *userIdResolver
+	// rdnsIpv6Addresses are the IPv6 host addresses whose reverse DNS entries are collected.
+	rdnsIpv6Addresses []netip.Addr
+	// servers are shared by all copies of the collector.
+	servers *serverCache
 }
 
 var _ ServerCollector = DefaultServerCollector{}
 
-func NewDefaultServerCollector(authenticator authenticator.Authenticator) (DefaultServerCollector, error) {
+// NewDefaultServerCollector creates a new DefaultServerCollector that sends requests using the given
+// authenticated doer and fetches up to the given number of servers concurrently. The user id is resolved
+// on first use and retried until it succeeds; it can be nil if unknown, in which case user specific data
+// is not collected. Reverse DNS entries are collected
+// for all IPv4 addresses and the given IPv6 host addresses.
+func NewDefaultServerCollector(doer client.HttpRequestDoer, workers int, userId UserIdFunc, rdnsIpv6Addresses []netip.Addr) (DefaultServerCollector, error) {
+	if workers < 1 {
+		return DefaultServerCollector{}, errors.New("number of workers must be at least 1")
+	}
 	// Prepare authenticated client.
-	client, err := client.NewClientWithResponses(scpBaseUrl, client.WithHTTPClient(authenticator.GetAuthenticatedClient()))
+	client, err := client.NewClientWithResponses(scpBaseUrl, client.WithHTTPClient(doer))
 	if err != nil {
 		return DefaultServerCollector{}, err
 	}
 	return DefaultServerCollector{
-		client: client,
+		client:  client,
+		workers: workers,
+		userId:  &userIdResolver{resolve: userId},
+
+		rdnsIpv6Addresses: rdnsIpv6Addresses,
+		servers:           &serverCache{},
 	}, nil
 }
 
-func (c DefaultServerCollector) CollectServerData(ctx context.Context) ([]ServerInfo, error) {
+func (c DefaultServerCollector) CollectServerData(ctx context.Context) (*ServerData, error) {
 	// Check API availability.
 	pingStatus, err := c.pingApi(ctx, c.client)
 	if err != nil {
@@ -45,29 +68,69 @@ func (c DefaultServerCollector) CollectServerData(ctx context.Context) ([]Server
 		slog.Error("error getting maintenance info", "error", err)
 		return nil, err
 	}
-	if isMaintenanceOngoing(maintenanceInfo, time.Now()) {
+	data := &ServerData{
+		Maintenance:       maintenanceInfo,
+		MaintenanceActive: isMaintenanceOngoing(maintenanceInfo, time.Now()),
+	}
+	if data.MaintenanceActive {
 		slog.Warn("maintenance is currently ongoing", "start_at", maintenanceInfo.StartAt, "finish_at", maintenanceInfo.FinishAt)
-		return nil, errors.New("maintenance is currently ongoing")
+		return data, ErrMaintenanceOngoing
 	}
 	slog.Debug("no ongoing maintenance detected")
 
-	serverListMinimal, err := c.getServerListMinimal(ctx, c.client)
+	serverListMinimal, err := c.listServers(ctx)
 	if err != nil {
-		slog.Error("error getting server list", "error", err)
 		return nil, err
 	}
-	if serverListMinimal == nil {
+	if len(serverListMinimal) == 0 {
 		slog.Warn("no servers found")
-		return []ServerInfo{}, nil
+		data.Servers = []ServerInfo{}
+		return data, nil
 	}
-	var servers = make([]ServerInfo, len(*serverListMinimal))
-	for i, srv := range *serverListMinimal {
+	data.Servers, err = c.getServers(ctx, serverListMinimal)
+	if err != nil {
+		return nil, err
+	}
+	c.servers.replace(data.Servers, time.Now())
+	return data, nil
+}
+
+// getServers fetches the given servers using a bounded number of workers.
+// The returned servers are in the same order as the given server list.
+// A server that could not be fetched is returned with its error set.
+func (c DefaultServerCollector) getServers(ctx context.Context, serverListMinimal []client.ServerListMinimal) ([]ServerInfo, error) {
+	servers := make([]ServerInfo, len(serverListMinimal))
+	err := forEachServer(ctx, c.workers, serverListMinimal, func(i int, srv client.ServerListMinimal) {
+		servers[i] = ServerInfo{ServerId: *srv.Id, ServerName: deref(srv.Name)}
 		server, err := c.getServer(ctx, *srv.Id, c.client)
 		if err != nil {
-			slog.Debug("error getting server", "serverId", *srv.Id, "error", err)
-			return nil, err
+			slog.Error("error getting server", "serverId", *srv.Id, "servername", servers[i].ServerName, "error", err)
+			servers[i].Err = err
+			return
+		}
+		servers[i].Server = server
+	})
+	if err != nil {
+		return nil, err
+	}
+	return servers, nil
+}
+
+// listServers returns all servers of the account, which is used by all data groups fetching data per server.
+func (c DefaultServerCollector) listServers(ctx context.Context) ([]client.ServerListMinimal, error) {
+	serverListMinimal, err := c.getServerListMinimal(ctx, c.client)
+	if err != nil {
+		slog.Error("error getting server list", "error", err)
+		return nil, err
+	}
+	// Servers without id cannot be fetched any further, so they are left out.
+	servers := make([]client.ServerListMinimal, 0, len(deref(serverListMinimal)))
+	for _, srv := range deref(serverListMinimal) {
+		if srv.Id == nil {
+			slog.Warn("skipping server without id", "servername", deref(srv.Name))
+			continue
 		}
-		servers[i] = ServerInfo{Server: server}
+		servers = append(servers, srv)
 	}
 	return servers, nil
 }
@@ -112,12 +175,23 @@ func (c DefaultServerCollector) getServerListMinimal(ctx context.Context, respCl
 	return serversResp.JSON200, nil
 }
 
+// getCachedServer returns the server fetched by the last refresh of the servers data group, or
+// fetches it if that refresh is too long ago or did not fetch it.
+func (c DefaultServerCollector) getCachedServer(ctx context.Context, serverId int32) (*client.Server, error) {
+	if server, ok := c.servers.get(serverId, time.Now()); ok {
+		return server, nil
+	}
+	return c.getServer(ctx, serverId, c.client)
+}
+
 func (c DefaultServerCollector) getServer(ctx context.Context, serverId int32, respClient *client.ClientWithResponses) (*client.Server, error) {
 	server, err := respClient.GetApiV1ServersServerIdWithResponse(ctx, serverId, &client.GetApiV1ServersServerIdParams{})
 	if err != nil {
 		return nil, err
 	} else if server.StatusCode() != http.StatusOK {
 		return nil, errors.New("unexpected status code when getting server: " + server.Status())
+	} else if server.JSON200 == nil {
+		return nil, errors.New("unexpected empty response when getting server")
 	}
 	return server.JSON200, nil
 }
@@ -0,0 +1,38 @@
+package collector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kodehat/netcupscp-exporter/internal/client"
+	"github.com/kodehat/netcupscp-exporter/internal/collector/collectortest"
+)
+
+// newTestCollector returns a collector fetching from a fake API serving the given data. The user id is
+// unknown if zero.
+func newTestCollector(t *testing.T, api *collectortest.API, userId int32) DefaultServerCollector {
+	t.Helper()
+	var userIdFunc UserIdFunc
+	if userId != 0 {
+		userIdFunc = func() (int32, error) { return userId, nil }
+	}
+	server := collectortest.NewServer(t, api)
+	c, err := NewDefaultServerCollector(collectortest.Doer(server), 2, userIdFunc, nil)
+	if err != nil {
+		t.Fatalf("NewDefaultServerCollector() error = %v", err)
+	}
+	return c
+}
+
+func TestListServersSkipsServersWithoutId(t *testing.T) {
+	servers := append(collectortest.NewServers(1, 2), client.Server{})
+	c := newTestCollector(t, &collectortest.API{Servers: servers}, 0)
+
+	got, err := c.listServers(context.Background())
+	if err != nil {
+		t.Fatalf("listServers() error = %v", err)
+	}
+	if len(got) != 2 || *got[0].Id != 1 || *got[1].Id != 2 {
+		t.Errorf("listServers() = %+v; want servers 1 and 2", got)
+	}
+}
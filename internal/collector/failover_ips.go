@@ -0,0 +1,49 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/kodehat/netcupscp-exporter/internal/client"
+)
+
+// ErrUserIdUnknown is returned when collecting user specific data without a user id.
+var ErrUserIdUnknown = errors.New("user id is unknown")
+
+// FailoverIps are the failover IPs of the user.
+type FailoverIps struct {
+	V4 []client.FailoverIPv4
+	V6 []client.FailoverIPv6
+}
+
+// CollectFailoverIps fetches the failover IPv4 addresses and IPv6 networks of the user.
+func (c DefaultServerCollector) CollectFailoverIps(ctx context.Context) (*FailoverIps, error) {
+	userId, err := c.userId.get()
+	if err != nil {
+		return nil, err
+	}
+	v4Resp, err := c.client.GetApiV1UsersUserIdFailoveripsV4WithResponse(ctx, userId, &client.GetApiV1UsersUserIdFailoveripsV4Params{})
+	if err != nil {
+		return nil, err
+	} else if v4Resp.StatusCode() != http.StatusOK {
+		return nil, errors.New("unexpected status code when getting failover IPv4 addresses: " + v4Resp.Status())
+	}
+	v6Resp, err := c.client.GetApiV1UsersUserIdFailoveripsV6WithResponse(ctx, userId, &client.GetApiV1UsersUserIdFailoveripsV6Params{})
+	if err != nil {
+		return nil, err
+	} else if v6Resp.StatusCode() != http.StatusOK {
+		return nil, errors.New("unexpected status code when getting failover IPv6 networks: " + v6Resp.Status())
+	}
+	failoverIps := &FailoverIps{
+		V4: []client.FailoverIPv4{},
+		V6: []client.FailoverIPv6{},
+	}
+	if v4Resp.JSON200 != nil {
+		failoverIps.V4 = *v4Resp.JSON200
+	}
+	if v6Resp.JSON200 != nil {
+		failoverIps.V6 = *v6Resp.JSON200
+	}
+	return failoverIps, nil
+}
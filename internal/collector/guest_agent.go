@@ -0,0 +1,141 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+// Keys of the known guest agent payloads, named after the QEMU guest agent commands.
+const (
+	guestAgentOsInfo    = "guest-get-osinfo"
+	guestAgentFsInfo    = "guest-get-fsinfo"
+	guestAgentHostName  = "guest-get-host-name"
+	guestAgentNetworkIf = "guest-network-get-interfaces"
+)
+
+// GuestAgentInfo is the data reported by the QEMU guest agent of a server. Payloads the guest agent
+// did not report are left empty.
+type GuestAgentInfo struct {
+	Available         bool
+	OsInfo            *GuestOsInfo
+	Filesystems       []GuestFilesystem
+	HostName          string
+	NetworkInterfaces []GuestNetworkInterface
+}
+
+type GuestOsInfo struct {
+	Id            string `json:"id"`
+	Name          string `json:"name"`
+	PrettyName    string `json:"pretty-name"`
+	Version       string `json:"version"`
+	VersionId     string `json:"version-id"`
+	KernelRelease string `json:"kernel-release"`
+	KernelVersion string `json:"kernel-version"`
+	Machine       string `json:"machine"`
+}
+
+type GuestFilesystem struct {
+	Name       string `json:"name"`
+	Mountpoint string `json:"mountpoint"`
+	Type       string `json:"type"`
+	// UsedBytes and TotalBytes are missing for filesystems the guest agent cannot inspect.
+	UsedBytes  *uint64 `json:"used-bytes"`
+	TotalBytes *uint64 `json:"total-bytes"`
+}
+
+type GuestNetworkInterface struct {
+	Name            string           `json:"name"`
+	HardwareAddress string           `json:"hardware-address"`
+	IpAddresses     []GuestIpAddress `json:"ip-addresses"`
+}
+
+type GuestIpAddress struct {
+	// Type is either "ipv4" or "ipv6".
+	Type    string `json:"ip-address-type"`
+	Address string `json:"ip-address"`
+	Prefix  int    `json:"prefix"`
+}
+
+type guestHostName struct {
+	HostName string `json:"host-name"`
+}
+
+// ServerGuestAgent is the guest agent data of a single server.
+type ServerGuestAgent = ServerResult[*GuestAgentInfo]
+
+// CollectGuestAgents fetches the guest agent data of every server.
+func (c DefaultServerCollector) CollectGuestAgents(ctx context.Context) ([]ServerGuestAgent, error) {
+	return collectPerServer(ctx, c, "guest agent", func(ctx context.Context, serverId int32) (*GuestAgentInfo, error) {
+		resp, err := c.client.GetApiV1ServersServerIdGuestAgentWithResponse(ctx, serverId)
+		if err != nil {
+			return nil, err
+		} else if resp.StatusCode() != http.StatusOK {
+			return nil, errors.New("unexpected status code when getting guest agent data: " + resp.Status())
+		} else if resp.JSON200 == nil {
+			return &GuestAgentInfo{}, nil
+		}
+		info := &GuestAgentInfo{Available: deref(resp.JSON200.GuestAgentAvailable)}
+		if !info.Available || resp.JSON200.GuestAgentData == nil {
+			return info, nil
+		}
+		if err := decodeGuestAgentData(*resp.JSON200.GuestAgentData, info); err != nil {
+			slog.Warn("skipping undecodable guest agent payloads", "serverId", serverId, "error", err)
+		}
+		return info, nil
+	})
+}
+
+// decodeGuestAgentData decodes the known payloads of the free-form guest agent data into the given info.
+// The format of the data depends on the guest agent version, so unknown payloads are ignored. A payload that
+// cannot be decoded is left empty and its error is returned, while all other payloads are still decoded.
+func decodeGuestAgentData(data map[string]any, info *GuestAgentInfo) error {
+	var errs []error
+	decode := func(key string, target any) bool {
+		value, ok := data[key]
+		if !ok || value == nil {
+			return false
+		}
+		if err := decodeGuestAgentPayload(value, target); err != nil {
+			errs = append(errs, fmt.Errorf("unable to decode guest agent payload %s: %w", key, err))
+			return false
+		}
+		return true
+	}
+
+	var osInfo GuestOsInfo
+	if decode(guestAgentOsInfo, &osInfo) {
+		info.OsInfo = &osInfo
+	}
+	var filesystems []GuestFilesystem
+	if decode(guestAgentFsInfo, &filesystems) {
+		info.Filesystems = filesystems
+	}
+	var hostName guestHostName
+	if decode(guestAgentHostName, &hostName) {
+		info.HostName = hostName.HostName
+	}
+	var networkInterfaces []GuestNetworkInterface
+	if decode(guestAgentNetworkIf, &networkInterfaces) {
+		info.NetworkInterfaces = networkInterfaces
+	}
+	return errors.Join(errs...)
+}
+
+// decodeGuestAgentPayload decodes a single payload, which is either the result of a guest agent
+// command or the raw guest agent response wrapping the result in a "return" object.
+func decodeGuestAgentPayload(value any, target any) error {
+	if wrapped, ok := value.(map[string]any); ok {
+		if result, ok := wrapped["return"]; ok {
+			value = result
+		}
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, target)
+}
@@ -0,0 +1,62 @@
+package collector
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestDecodeGuestAgentData(t *testing.T) {
+	body, err := os.ReadFile("testdata/guest_agent.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data map[string]any
+	if err := json.Unmarshal(body, &data); err != nil {
+		t.Fatal(err)
+	}
+
+	info := &GuestAgentInfo{Available: true}
+	if err := decodeGuestAgentData(data, info); err != nil {
+		t.Fatalf("decodeGuestAgentData() error = %v", err)
+	}
+
+	if info.OsInfo == nil || info.OsInfo.PrettyName != "Debian GNU/Linux 12 (bookworm)" {
+		t.Errorf("OsInfo = %+v; want Debian GNU/Linux 12 (bookworm)", info.OsInfo)
+	}
+	if info.HostName != "mail" {
+		t.Errorf("HostName = %q; want %q", info.HostName, "mail")
+	}
+	if got := len(info.Filesystems); got != 2 {
+		t.Fatalf("len(Filesystems) = %d; want 2", got)
+	}
+	if fs := info.Filesystems[0]; fs.UsedBytes == nil || *fs.UsedBytes != 5368709120 || fs.TotalBytes == nil || *fs.TotalBytes != 21474836480 {
+		t.Errorf("Filesystems[0] = %+v; want used 5368709120 of 21474836480 bytes", fs)
+	}
+	if fs := info.Filesystems[1]; fs.UsedBytes != nil || fs.TotalBytes != nil {
+		t.Errorf("Filesystems[1] = %+v; want no usage", fs)
+	}
+	if got := len(info.NetworkInterfaces); got != 1 {
+		t.Fatalf("len(NetworkInterfaces) = %d; want 1", got)
+	}
+	if got := info.NetworkInterfaces[0].IpAddresses[1].Address; got != "2a03:4000:1:2::1" {
+		t.Errorf("IpAddresses[1].Address = %q; want %q", got, "2a03:4000:1:2::1")
+	}
+}
+
+func TestDecodeGuestAgentDataInvalidPayload(t *testing.T) {
+	data := map[string]any{
+		guestAgentFsInfo:   "not a list",
+		guestAgentHostName: map[string]any{"return": map[string]any{"host-name": "mail"}},
+	}
+	info := &GuestAgentInfo{Available: true}
+	if err := decodeGuestAgentData(data, info); err == nil {
+		t.Errorf("decodeGuestAgentData() error = nil; want error")
+	}
+	if info.Filesystems != nil {
+		t.Errorf("Filesystems = %+v; want none", info.Filesystems)
+	}
+	if !info.Available || info.HostName != "mail" {
+		t.Errorf("info = %+v; want available with host name mail", info)
+	}
+}
@@ -0,0 +1,282 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/kodehat/netcupscp-exporter/internal/client"
+)
+
+const (
+	// logPageSize is the number of log entries fetched per request.
+	logPageSize int32 = 50
+	// maxLogPages limits the number of requests per log stream on the first run, so that it does
+	// not fetch the complete history.
+	maxLogPages = 10
+	// maxBacklogLogPages limits the number of requests per log stream on later runs. It is only
+	// reached if a lot of entries piled up since the last run, e.g. while the exporter was down.
+	maxBacklogLogPages = 100
+	// maxLogPageProbe is the highest page probed when searching for the last page of a stream.
+	maxLogPageProbe int32 = 1 << 20
+)
+
+// LogStream identifies the logs of a single server or of the account itself.
+type LogStream struct {
+	// ServerId is zero for the logs of the account.
+	ServerId   int32
+	ServerName string
+}
+
+// IsAccount tells if the stream contains the logs of the account instead of a server.
+func (s LogStream) IsAccount() bool {
+	return s.ServerId == 0
+}
+
+// StreamLogs are the new log entries of a single stream.
+type StreamLogs struct {
+	Stream LogStream
+	Logs   []client.Log
+	// Err is set if fetching the logs failed, Logs is nil in this case.
+	Err error
+}
+
+// CollectLogs fetches the log entries of every server and of the account that are not older than the time
+// returned by since for their stream. Entries dated exactly since are included, as several entries may
+// share a time; it is up to the caller to skip those it has seen already. The account logs are left out
+// without a way to resolve the user id and returned with an error if resolving it fails.
+func (c DefaultServerCollector) CollectLogs(ctx context.Context, since func(stream LogStream) time.Time) ([]StreamLogs, error) {
+	servers, err := c.listServers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	streamLogs := make([]StreamLogs, len(servers))
+	err = forEachServer(ctx, c.workers, servers, func(i int, srv client.ServerListMinimal) {
+		stream := LogStream{ServerId: *srv.Id, ServerName: deref(srv.Name)}
+		streamLogs[i].Stream = stream
+		logs, err := c.getNewLogs(ctx, stream, since(stream), func(ctx context.Context, params pageParams) (*[]client.Log, *http.Response, error) {
+			resp, err := c.client.GetApiV1ServersServerIdLogsWithResponse(ctx, *srv.Id, &client.GetApiV1ServersServerIdLogsParams{Limit: params.limit, Offset: params.offset})
+			if err != nil {
+				return nil, nil, err
+			}
+			return resp.JSON200, resp.HTTPResponse, nil
+		})
+		if err != nil {
+			slog.Error("error getting server logs", "serverId", *srv.Id, "error", err)
+			streamLogs[i].Err = err
+			return
+		}
+		streamLogs[i].Logs = logs
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !c.userId.available() {
+		slog.Debug("skipping account logs without user id")
+		return streamLogs, nil
+	}
+	accountStream := StreamLogs{Stream: LogStream{}}
+	userId, err := c.userId.get()
+	if err != nil {
+		slog.Error("error resolving user id for account logs", "error", err)
+		accountStream.Err = err
+		return append(streamLogs, accountStream), nil
+	}
+	accountStream.Logs, accountStream.Err = c.getNewLogs(ctx, accountStream.Stream, since(accountStream.Stream), func(ctx context.Context, params pageParams) (*[]client.Log, *http.Response, error) {
+		resp, err := c.client.GetApiV1UsersUserIdLogsWithResponse(ctx, userId, &client.GetApiV1UsersUserIdLogsParams{Limit: params.limit, Offset: params.offset})
+		if err != nil {
+			return nil, nil, err
+		}
+		return resp.JSON200, resp.HTTPResponse, nil
+	})
+	if accountStream.Err != nil {
+		slog.Error("error getting account logs", "error", accountStream.Err)
+	}
+	return append(streamLogs, accountStream), nil
+}
+
+type pageParams struct {
+	limit, offset *int32
+}
+
+// getNewLogs fetches pages of log entries and returns the most recent entries not older than since. The
+// order of the entries is not documented, so it is detected from the first page: if the logs are returned
+// newest first, pages are fetched from the start until an entry older than since shows up. Otherwise
+// the last page is searched for and pages are fetched backwards from it. Both is limited to the entries of
+// a number of pages, in which case a warning is logged for streams that have been shipped before, as
+// entries between since and the returned ones are skipped.
+func (c DefaultServerCollector) getNewLogs(ctx context.Context, stream LogStream, since time.Time, fetchPage func(ctx context.Context, params pageParams) (*[]client.Log, *http.Response, error)) ([]client.Log, error) {
+	maxPages := int32(maxLogPages)
+	if !since.IsZero() {
+		maxPages = maxBacklogLogPages
+	}
+
+	pages := logPages{fetchPage: fetchPage, fetched: map[int32][]client.Log{}}
+	first, err := pages.get(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	var newLogs []client.Log
+	complete := false
+	if isOldestFirst(first) {
+		newLogs, complete, err = pages.newLogsOldestFirst(ctx, since, maxPages)
+	} else {
+		newLogs, complete, err = pages.newLogsNewestFirst(ctx, since, maxPages)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !complete && !since.IsZero() {
+		slog.Warn("log page limit reached, older log entries are skipped",
+			"serverId", stream.ServerId, "servername", stream.ServerName, "since", since, "pages", maxPages, "entries", len(newLogs))
+	}
+	return newLogs, nil
+}
+
+// logPages fetches the pages of a log stream, each of them only once.
+type logPages struct {
+	fetchPage func(ctx context.Context, params pageParams) (*[]client.Log, *http.Response, error)
+	fetched   map[int32][]client.Log
+}
+
+func (p logPages) get(ctx context.Context, page int32) ([]client.Log, error) {
+	if logs, ok := p.fetched[page]; ok {
+		return logs, nil
+	}
+	limit, offset := logPageSize, page*logPageSize
+	logs, resp, err := p.fetchPage(ctx, pageParams{limit: &limit, offset: &offset})
+	if err != nil {
+		return nil, err
+	} else if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("unexpected status code when getting logs: " + resp.Status)
+	}
+	var pageLogs []client.Log
+	if logs != nil {
+		pageLogs = *logs
+	}
+	p.fetched[page] = pageLogs
+	return pageLogs, nil
+}
+
+// newLogsNewestFirst returns the entries not older than since of at most maxPages pages from the start. It
+// tells if all of them have been returned.
+func (p logPages) newLogsNewestFirst(ctx context.Context, since time.Time, maxPages int32) ([]client.Log, bool, error) {
+	var newLogs []client.Log
+	for page := range maxPages {
+		logs, err := p.get(ctx, page)
+		if err != nil {
+			return nil, false, err
+		}
+		pageLogs, reachedSince := logsSince(logs, since)
+		newLogs = append(newLogs, pageLogs...)
+		if reachedSince || int32(len(logs)) < logPageSize {
+			return newLogs, true, nil
+		}
+	}
+	return newLogs, false, nil
+}
+
+// newLogsOldestFirst returns the most recent entries not older than since, at most as many as fit into
+// maxPages pages, by fetching the pages backwards from the last one. It tells if all of them have been
+// returned.
+func (p logPages) newLogsOldestFirst(ctx context.Context, since time.Time, maxPages int32) ([]client.Log, bool, error) {
+	last, err := p.lastPage(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// The last page is usually not full, so one more page is needed to fill up the limit.
+	var newPages [][]client.Log
+	entries, complete := 0, false
+	for page := last; page >= 0 && page >= last-maxPages; page-- {
+		logs, err := p.get(ctx, page)
+		if err != nil {
+			return nil, false, err
+		}
+		pageLogs, reachedSince := logsSince(logs, since)
+		newPages = append(newPages, pageLogs)
+		entries += len(pageLogs)
+		if reachedSince || page == 0 {
+			complete = true
+			break
+		} else if entries >= int(maxPages*logPageSize) {
+			break
+		}
+	}
+
+	newLogs := make([]client.Log, 0, entries)
+	for _, pageLogs := range slices.Backward(newPages) {
+		newLogs = append(newLogs, pageLogs...)
+	}
+	if excess := len(newLogs) - int(maxPages*logPageSize); excess > 0 {
+		return newLogs[excess:], false, nil
+	}
+	return newLogs, complete, nil
+}
+
+// lastPage returns the first page that is not full, given that the first page is. It probes pages of
+// exponentially growing index until one is not full and bisects the range in between afterwards.
+func (p logPages) lastPage(ctx context.Context) (int32, error) {
+	full, notFull := int32(0), int32(1)
+	for {
+		logs, err := p.get(ctx, notFull)
+		if err != nil {
+			return 0, err
+		} else if int32(len(logs)) < logPageSize {
+			break
+		} else if notFull >= maxLogPageProbe {
+			return 0, fmt.Errorf("no end of logs found within %d pages", maxLogPageProbe)
+		}
+		full, notFull = notFull, notFull*2
+	}
+	for notFull-full > 1 {
+		page := full + (notFull-full)/2
+		logs, err := p.get(ctx, page)
+		if err != nil {
+			return 0, err
+		} else if int32(len(logs)) < logPageSize {
+			notFull = page
+		} else {
+			full = page
+		}
+	}
+	return notFull, nil
+}
+
+// logsSince returns the dated entries not older than since and tells if the page contains an older entry.
+func logsSince(logs []client.Log, since time.Time) ([]client.Log, bool) {
+	var newer []client.Log
+	reachedSince := false
+	for _, log := range logs {
+		if log.Date == nil {
+			continue
+		} else if log.Date.Before(since) {
+			reachedSince = true
+			continue
+		}
+		newer = append(newer, log)
+	}
+	return newer, reachedSince
+}
+
+// isOldestFirst tells if the dated entries of the page are sorted from oldest to newest.
+// Pages with less than two distinct dates are taken as newest first.
+func isOldestFirst(logs []client.Log) bool {
+	var first, last *time.Time
+	for _, log := range logs {
+		if log.Date == nil {
+			continue
+		}
+		if first == nil {
+			first = log.Date
+		}
+		last = log.Date
+	}
+	return first != nil && first.Before(*last)
+}
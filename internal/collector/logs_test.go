@@ -0,0 +1,127 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kodehat/netcupscp-exporter/internal/client"
+	"github.com/kodehat/netcupscp-exporter/internal/collector/collectortest"
+)
+
+var logsEpoch = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// newLogs returns count entries one minute apart, the newest first unless oldestFirst is set.
+// The entry at index i of the newest first order is dated logsEpoch plus count-1-i minutes.
+func newLogs(count int, oldestFirst bool) []client.Log {
+	logs := make([]client.Log, count)
+	for i := range logs {
+		date := logsEpoch.Add(time.Duration(count-1-i) * time.Minute)
+		message := fmt.Sprintf("entry %d", i)
+		logs[i] = client.Log{Date: &date, Message: &message}
+	}
+	if oldestFirst {
+		slices.Reverse(logs)
+	}
+	return logs
+}
+
+func TestGetNewLogs(t *testing.T) {
+	tests := []struct {
+		name         string
+		logs         []client.Log
+		since        time.Time
+		wantLogs     int
+		wantRequests int32
+	}{
+		{"newest first stops at cursor", newLogs(120, false), logsEpoch.Add(59 * time.Minute), 61, 2},
+		{"oldest first reads backwards from the last page", newLogs(120, true), logsEpoch.Add(59 * time.Minute), 61, 3},
+		{"only the entry at the cursor", newLogs(120, false), logsEpoch.Add(119 * time.Minute), 1, 1},
+		{"first run is limited", newLogs(1000, false), time.Time{}, 500, 10},
+		{"later runs fetch the backlog", newLogs(1000, false), logsEpoch.Add(199 * time.Minute), 801, 17},
+		{"oldest first first run is limited", newLogs(6000, true), time.Time{}, 500, 21},
+		{"oldest first later runs reach the newest", newLogs(6000, true), logsEpoch.Add(4999 * time.Minute), 1001, 32},
+		{"oldest first backlog is limited", newLogs(6000, true), logsEpoch.Add(99 * time.Minute), 5000, 108},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &collectortest.API{Servers: collectortest.NewServers(1), ServerLogs: map[int32][]client.Log{1: tt.logs}}
+			c := newTestCollector(t, api, 0)
+
+			streamLogs, err := c.CollectLogs(context.Background(), func(LogStream) time.Time { return tt.since })
+			if err != nil {
+				t.Fatalf("CollectLogs() error = %v", err)
+			}
+			if len(streamLogs) != 1 || streamLogs[0].Err != nil {
+				t.Fatalf("CollectLogs() = %+v; want a single successful stream", streamLogs)
+			}
+			if got := len(streamLogs[0].Logs); got != tt.wantLogs {
+				t.Errorf("logs = %d; want %d", got, tt.wantLogs)
+			}
+			newest := time.Time{}
+			for _, log := range streamLogs[0].Logs {
+				if log.Date.Before(tt.since) {
+					t.Errorf("log dated %v is older than %v", log.Date, tt.since)
+				}
+				if log.Date.After(newest) {
+					newest = *log.Date
+				}
+			}
+			if want := logsEpoch.Add(time.Duration(len(tt.logs)-1) * time.Minute); tt.wantLogs > 0 && !newest.Equal(want) {
+				t.Errorf("newest log dated %v; want %v", newest, want)
+			}
+			if got := api.LogRequests(); got != tt.wantRequests {
+				t.Errorf("requests = %d; want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestCollectLogsStreams(t *testing.T) {
+	tests := []struct {
+		name        string
+		userId      int32
+		wantAccount bool
+	}{
+		{"without user id", 0, false},
+		{"with user id", 7, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Server 2 fails to respond.
+			api := &collectortest.API{
+				Servers:    collectortest.NewServers(1, 2),
+				ServerLogs: map[int32][]client.Log{1: newLogs(3, false)},
+				UserLogs:   newLogs(2, false),
+			}
+			c := newTestCollector(t, api, tt.userId)
+
+			streamLogs, err := c.CollectLogs(context.Background(), func(LogStream) time.Time { return time.Time{} })
+			if err != nil {
+				t.Fatalf("CollectLogs() error = %v", err)
+			}
+
+			streams := map[LogStream]StreamLogs{}
+			for _, sl := range streamLogs {
+				streams[sl.Stream] = sl
+			}
+			if sl := streams[LogStream{ServerId: 1, ServerName: "v1"}]; sl.Err != nil || len(sl.Logs) != 3 {
+				t.Errorf("stream of server 1 = %+v; want 3 logs", sl)
+			}
+			if sl := streams[LogStream{ServerId: 2, ServerName: "v2"}]; sl.Err == nil || sl.Logs != nil {
+				t.Errorf("stream of server 2 = %+v; want error without logs", sl)
+			}
+			sl, ok := streams[LogStream{}]
+			if ok != tt.wantAccount {
+				t.Fatalf("account stream present = %v; want %v", ok, tt.wantAccount)
+			}
+			if ok && (sl.Err != nil || len(sl.Logs) != 2) {
+				t.Errorf("account stream = %+v; want 2 logs", sl)
+			}
+		})
+	}
+}
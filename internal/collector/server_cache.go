@@ -0,0 +1,44 @@
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kodehat/netcupscp-exporter/internal/client"
+)
+
+// maxCachedServerAge is the age up to which servers fetched by the servers data group are reused.
+const maxCachedServerAge = time.Hour
+
+// serverCache keeps the servers fetched by the last refresh of the servers data group, so that other
+// data groups needing the full server do not have to request it again.
+type serverCache struct {
+	mu        sync.Mutex
+	servers   map[int32]*client.Server
+	fetchedAt time.Time
+}
+
+// replace drops all cached servers and keeps the successfully fetched ones of the given servers.
+func (sc *serverCache) replace(servers []ServerInfo, fetchedAt time.Time) {
+	cached := make(map[int32]*client.Server, len(servers))
+	for _, server := range servers {
+		if server.Err == nil && server.Server != nil {
+			cached[server.ServerId] = server.Server
+		}
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.servers = cached
+	sc.fetchedAt = fetchedAt
+}
+
+// get returns the cached server with the given id unless it is older than maxCachedServerAge.
+func (sc *serverCache) get(serverId int32, now time.Time) (*client.Server, bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if now.Sub(sc.fetchedAt) > maxCachedServerAge {
+		return nil, false
+	}
+	server, ok := sc.servers[serverId]
+	return server, ok
+}
@@ -2,14 +2,54 @@ package collector
 
 import (
 	"context"
+	"time"
 
 	"github.com/kodehat/netcupscp-exporter/internal/client"
 )
 
 type ServerInfo struct {
 	*client.Server
+	// ServerId is the id of the server, also set if fetching the server failed.
+	ServerId int32
+	// ServerName is the name of the server, also set if fetching the server failed.
+	ServerName string
+	// Err is set if fetching the server failed, Server is nil in this case.
+	Err error
+}
+
+// ServerData is the data collected during a single refresh.
+type ServerData struct {
+	// Maintenance is the current or next maintenance window, if any is announced.
+	Maintenance *client.Maintenance
+	// MaintenanceActive is set if the maintenance window is ongoing. Servers are not collected in this case.
+	MaintenanceActive bool
+	Servers           []ServerInfo
 }
 
 type ServerCollector interface {
-	CollectServerData(context context.Context) ([]ServerInfo, error)
+	// CollectServerData collects the data of all servers. During maintenance it returns
+	// the maintenance info together with ErrMaintenanceOngoing.
+	CollectServerData(context context.Context) (*ServerData, error)
+	// CollectCpuMetrics collects the CPU utilisation of the last given hours of all servers.
+	CollectCpuMetrics(context context.Context, hours int32) ([]ServerMetrics, error)
+	// CollectDiskMetrics collects the disk throughput and IOPS of the last given hours of all servers.
+	CollectDiskMetrics(context context.Context, hours int32) ([]ServerMetrics, error)
+	// CollectNetworkMetrics collects the network throughput and packet rates of the last given hours of all servers.
+	CollectNetworkMetrics(context context.Context, hours int32) ([]ServerMetrics, error)
+	// CollectSnapshots collects the snapshots of all servers.
+	CollectSnapshots(context context.Context) ([]ServerSnapshots, error)
+	// CollectTasks collects the most recent tasks of all servers.
+	CollectTasks(context context.Context) ([]ServerTasks, error)
+	// CollectFailoverIps collects the failover IPs of the user. It returns ErrUserIdUnknown if the user id cannot be resolved.
+	CollectFailoverIps(context context.Context) (*FailoverIps, error)
+	// CollectFirewalls collects the firewalls of all interfaces of all servers.
+	CollectFirewalls(context context.Context) ([]ServerFirewalls, error)
+	// CollectVlans collects the VLANs of the user. It returns ErrUserIdUnknown if the user id cannot be resolved.
+	CollectVlans(context context.Context) ([]client.VLan, error)
+	// CollectRdns collects the reverse DNS entries of the addresses of all servers.
+	CollectRdns(context context.Context) ([]ServerRdns, error)
+	// CollectGuestAgents collects the QEMU guest agent data of all servers.
+	CollectGuestAgents(context context.Context) ([]ServerGuestAgent, error)
+	// CollectLogs collects the new log entries of all servers and of the account.
+	CollectLogs(context context.Context, since func(stream LogStream) time.Time) ([]StreamLogs, error)
 }
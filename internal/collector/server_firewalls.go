@@ -0,0 +1,58 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/kodehat/netcupscp-exporter/internal/client"
+)
+
+// InterfaceFirewall is the firewall of a single interface.
+type InterfaceFirewall struct {
+	Mac      string
+	Firewall *client.ServerFirewall
+}
+
+// ServerFirewalls are the firewalls of all interfaces of a single server.
+type ServerFirewalls = ServerResult[[]InterfaceFirewall]
+
+// CollectFirewalls fetches the firewalls of all interfaces of every server. The firewalls are checked
+// for consistency, so that their consistent attribute tells if the rules have been applied.
+func (c DefaultServerCollector) CollectFirewalls(ctx context.Context) ([]ServerFirewalls, error) {
+	return collectPerServer(ctx, c, "firewalls", func(ctx context.Context, serverId int32) ([]InterfaceFirewall, error) {
+		ifaces, err := c.getInterfaces(ctx, serverId)
+		if err != nil {
+			return nil, err
+		}
+		firewalls := make([]InterfaceFirewall, 0, len(ifaces))
+		for _, iface := range ifaces {
+			if iface.Mac == nil {
+				continue
+			}
+			consistencyCheck := true
+			resp, err := c.client.GetApiV1ServersServerIdInterfacesMacFirewallWithResponse(ctx, serverId, *iface.Mac, &client.GetApiV1ServersServerIdInterfacesMacFirewallParams{ConsistencyCheck: &consistencyCheck})
+			if err != nil {
+				return nil, err
+			} else if resp.StatusCode() != http.StatusOK {
+				return nil, errors.New("unexpected status code when getting firewall: " + resp.Status())
+			} else if resp.JSON200 == nil {
+				return nil, errors.New("unexpected empty response when getting firewall")
+			}
+			firewalls = append(firewalls, InterfaceFirewall{Mac: *iface.Mac, Firewall: resp.JSON200})
+		}
+		return firewalls, nil
+	})
+}
+
+func (c DefaultServerCollector) getInterfaces(ctx context.Context, serverId int32) ([]client.Interface, error) {
+	resp, err := c.client.GetApiV1ServersServerIdInterfacesWithResponse(ctx, serverId, &client.GetApiV1ServersServerIdInterfacesParams{})
+	if err != nil {
+		return nil, err
+	} else if resp.StatusCode() != http.StatusOK {
+		return nil, errors.New("unexpected status code when getting interfaces: " + resp.Status())
+	} else if resp.JSON200 == nil {
+		return []client.Interface{}, nil
+	}
+	return *resp.JSON200, nil
+}
@@ -0,0 +1,159 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/kodehat/netcupscp-exporter/internal/client"
+)
+
+// MetricSeriesSet is the payload of the metrics endpoints of a server, which the OpenAPI spec
+// only describes as a free-form object. It consists of named series of data points, optionally
+// distinguished by labels like the mac address of an interface. As the shape is not documented,
+// payloads that do not match it are rejected instead of being taken as a set without data.
+type MetricSeriesSet struct {
+	Series []MetricSeries `json:"series"`
+}
+
+// MetricSeries is a single series of data points ordered by time.
+type MetricSeries struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Values []DataPoint       `json:"values"`
+}
+
+// Names of the series returned by the metrics endpoints.
+const (
+	SeriesCpuUtilization = "cpu_utilization"
+	SeriesDiskReadBytes  = "read_bytes"
+	SeriesDiskWriteBytes = "write_bytes"
+	SeriesDiskReadOps    = "read_ops"
+	SeriesDiskWriteOps   = "write_ops"
+	SeriesNetRxBits      = "rx_bits"
+	SeriesNetTxBits      = "tx_bits"
+	SeriesNetRxPackets   = "rx_packets"
+	SeriesNetTxPackets   = "tx_packets"
+)
+
+// Labels of the series returned by the metrics endpoints.
+const (
+	SeriesLabelDevice = "device"
+	SeriesLabelMac    = "mac"
+)
+
+type DataPoint struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+}
+
+// ServerMetrics are the metric series of a single server.
+type ServerMetrics = ServerResult[*MetricSeriesSet]
+
+// ErrUnexpectedMetricsPayload is returned if the payload of a metrics endpoint does not have the expected shape.
+var ErrUnexpectedMetricsPayload = errors.New("unexpected metrics payload")
+
+func decodeMetricSeriesSet(body []byte) (*MetricSeriesSet, error) {
+	var payload struct {
+		Series *[]struct {
+			Name   *string           `json:"name"`
+			Labels map[string]string `json:"labels"`
+			Values *[]DataPoint      `json:"values"`
+		} `json:"series"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnexpectedMetricsPayload, err)
+	} else if payload.Series == nil {
+		return nil, fmt.Errorf("%w: missing series", ErrUnexpectedMetricsPayload)
+	}
+
+	set := &MetricSeriesSet{Series: make([]MetricSeries, 0, len(*payload.Series))}
+	for i, series := range *payload.Series {
+		if series.Name == nil || series.Values == nil {
+			return nil, fmt.Errorf("%w: series %d has no name or values", ErrUnexpectedMetricsPayload, i)
+		}
+		set.Series = append(set.Series, MetricSeries{Name: *series.Name, Labels: series.Labels, Values: *series.Values})
+	}
+	return set, nil
+}
+
+// Find returns all series with the given name.
+func (s MetricSeriesSet) Find(name string) []MetricSeries {
+	var found []MetricSeries
+	for _, series := range s.Series {
+		if series.Name == name {
+			found = append(found, series)
+		}
+	}
+	return found
+}
+
+// Latest returns the most recent data point of the series.
+func (s MetricSeries) Latest() (DataPoint, bool) {
+	var latest DataPoint
+	for _, point := range s.Values {
+		if point.Time.After(latest.Time) {
+			latest = point
+		}
+	}
+	return latest, !latest.Time.IsZero()
+}
+
+// CollectCpuMetrics fetches the CPU utilisation of the last given hours of every server.
+func (c DefaultServerCollector) CollectCpuMetrics(ctx context.Context, hours int32) ([]ServerMetrics, error) {
+	return collectPerServer(ctx, c, "cpu", func(ctx context.Context, serverId int32) (*MetricSeriesSet, error) {
+		resp, err := c.client.GetApiV1ServersServerIdMetricsCpuWithResponse(ctx, serverId, &client.GetApiV1ServersServerIdMetricsCpuParams{Hours: &hours})
+		if err != nil {
+			return nil, err
+		} else if resp.StatusCode() != http.StatusOK {
+			return nil, errors.New("unexpected status code when getting cpu metrics: " + resp.Status())
+		}
+		return decodeMetricSeriesSet(resp.Body)
+	})
+}
+
+// CollectDiskMetrics fetches the disk throughput and IOPS of the last given hours of every server.
+func (c DefaultServerCollector) CollectDiskMetrics(ctx context.Context, hours int32) ([]ServerMetrics, error) {
+	return collectPerServer(ctx, c, "disk", func(ctx context.Context, serverId int32) (*MetricSeriesSet, error) {
+		resp, err := c.client.GetApiV1ServersServerIdMetricsDiskWithResponse(ctx, serverId, &client.GetApiV1ServersServerIdMetricsDiskParams{Hours: &hours})
+		if err != nil {
+			return nil, err
+		} else if resp.StatusCode() != http.StatusOK {
+			return nil, errors.New("unexpected status code when getting disk metrics: " + resp.Status())
+		}
+		return decodeMetricSeriesSet(resp.Body)
+	})
+}
+
+// CollectNetworkMetrics fetches the network throughput and packet rates of the last given hours of every server.
+// The series of both endpoints are merged into a single set per server.
+func (c DefaultServerCollector) CollectNetworkMetrics(ctx context.Context, hours int32) ([]ServerMetrics, error) {
+	return collectPerServer(ctx, c, "network", func(ctx context.Context, serverId int32) (*MetricSeriesSet, error) {
+		resp, err := c.client.GetApiV1ServersServerIdMetricsNetworkWithResponse(ctx, serverId, &client.GetApiV1ServersServerIdMetricsNetworkParams{Hours: &hours})
+		if err != nil {
+			return nil, err
+		} else if resp.StatusCode() != http.StatusOK {
+			return nil, errors.New("unexpected status code when getting network metrics: " + resp.Status())
+		}
+		set, err := decodeMetricSeriesSet(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		packetResp, err := c.client.GetApiV1ServersServerIdMetricsNetworkPacketWithResponse(ctx, serverId, &client.GetApiV1ServersServerIdMetricsNetworkPacketParams{Hours: &hours})
+		if err != nil {
+			return nil, err
+		} else if packetResp.StatusCode() != http.StatusOK {
+			return nil, errors.New("unexpected status code when getting network packet metrics: " + packetResp.Status())
+		}
+		packetSet, err := decodeMetricSeriesSet(packetResp.Body)
+		if err != nil {
+			return nil, err
+		}
+		set.Series = append(set.Series, packetSet.Series...)
+		return set, nil
+	})
+}
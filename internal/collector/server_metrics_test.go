@@ -0,0 +1,152 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/kodehat/netcupscp-exporter/internal/collector/collectortest"
+)
+
+// The metrics fixtures in testdata are not captured from the API, whose payloads are undocumented. They
+// follow the shape decodeMetricSeriesSet expects and cover the orderings and gaps it has to deal with.
+
+func TestDecodeMetricSeriesSet(t *testing.T) {
+	tests := []struct {
+		name       string
+		fixture    string
+		series     string
+		label      string
+		labelValue string
+		wantValue  float64
+		wantLatest bool
+	}{
+		{"disk latest regardless of order", "metrics_disk.json", SeriesDiskReadBytes, SeriesLabelDevice, "vda", 4096, true},
+		{"disk latest of two", "metrics_disk.json", SeriesDiskWriteBytes, SeriesLabelDevice, "vda", 256, true},
+		{"disk fractional value", "metrics_disk.json", SeriesDiskReadOps, SeriesLabelDevice, "vda", 12.5, true},
+		{"disk no values", "metrics_disk.json", SeriesDiskWriteOps, SeriesLabelDevice, "vda", 0, false},
+		{"disk second device", "metrics_disk.json", SeriesDiskReadBytes, SeriesLabelDevice, "vdb", 0, true},
+		{"network latest first", "metrics_network.json", SeriesNetRxBits, SeriesLabelMac, "52:54:00:12:34:56", 800000, true},
+		{"network fractional value", "metrics_network.json", SeriesNetTxBits, SeriesLabelMac, "52:54:00:12:34:56", 96000.5, true},
+		{"network no values", "metrics_network.json", SeriesNetRxBits, SeriesLabelMac, "52:54:00:ab:cd:ef", 0, false},
+		{"packets latest last", "metrics_network_packet.json", SeriesNetRxPackets, SeriesLabelMac, "52:54:00:12:34:56", 85, true},
+		{"packets fractional value", "metrics_network_packet.json", SeriesNetTxPackets, SeriesLabelMac, "52:54:00:12:34:56", 12.5, true},
+		{"packets second interface", "metrics_network_packet.json", SeriesNetRxPackets, SeriesLabelMac, "52:54:00:ab:cd:ef", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := os.ReadFile("testdata/" + tt.fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+			set, err := decodeMetricSeriesSet(body)
+			if err != nil {
+				t.Fatalf("decodeMetricSeriesSet() error = %v", err)
+			}
+
+			var found bool
+			for _, series := range set.Find(tt.series) {
+				if series.Labels[tt.label] != tt.labelValue {
+					continue
+				}
+				found = true
+				latest, ok := series.Latest()
+				if ok != tt.wantLatest {
+					t.Errorf("Latest() ok = %v; want %v", ok, tt.wantLatest)
+				}
+				if latest.Value != tt.wantValue {
+					t.Errorf("Latest() = %v; want %v", latest.Value, tt.wantValue)
+				}
+			}
+			if !found {
+				t.Errorf("Find(%q) found no series with %s %s", tt.series, tt.label, tt.labelValue)
+			}
+		})
+	}
+}
+
+func TestDecodeMetricSeriesSetUnexpected(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"series not a list", `{"series": {}}`},
+		{"no series", `{"cpu": [{"timestamp": 1735725600, "utilization": 12.5}]}`},
+		{"empty object", `{}`},
+		{"series without name", `{"series": [{"values": []}]}`},
+		{"series without values", `{"series": [{"name": "cpu_utilization"}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeMetricSeriesSet([]byte(tt.body)); !errors.Is(err, ErrUnexpectedMetricsPayload) {
+				t.Errorf("decodeMetricSeriesSet() error = %v; want %v", err, ErrUnexpectedMetricsPayload)
+			}
+		})
+	}
+}
+
+func TestCollectDiskMetrics(t *testing.T) {
+	body, err := os.ReadFile("testdata/metrics_disk.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Server 2 fails to respond.
+	api := &collectortest.API{Servers: collectortest.NewServers(1, 2), Metrics: map[int32]map[string][]byte{1: {"disk": body}}}
+	c := newTestCollector(t, api, 0)
+
+	metrics, err := c.CollectDiskMetrics(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("CollectDiskMetrics() error = %v", err)
+	}
+	if len(metrics) != 2 {
+		t.Fatalf("CollectDiskMetrics() = %+v; want 2 servers", metrics)
+	}
+	if metrics[0].Err != nil || len(metrics[0].Data.Series) != 5 {
+		t.Errorf("metrics of server 1 = %+v; want 5 series", metrics[0])
+	}
+	if metrics[1].Err == nil || metrics[1].Data != nil {
+		t.Errorf("metrics of server 2 = %+v; want error without data", metrics[1])
+	}
+}
+
+func TestCollectNetworkMetrics(t *testing.T) {
+	network, err := os.ReadFile("testdata/metrics_network.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	packet, err := os.ReadFile("testdata/metrics_network_packet.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The packet endpoint of server 2 fails to respond.
+	api := &collectortest.API{
+		Servers: collectortest.NewServers(1, 2),
+		Metrics: map[int32]map[string][]byte{
+			1: {"network": network, "network/packet": packet},
+			2: {"network": network},
+		},
+	}
+	c := newTestCollector(t, api, 0)
+
+	metrics, err := c.CollectNetworkMetrics(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("CollectNetworkMetrics() error = %v", err)
+	}
+	if len(metrics) != 2 {
+		t.Fatalf("CollectNetworkMetrics() = %+v; want 2 servers", metrics)
+	}
+	if metrics[0].Err != nil {
+		t.Fatalf("metrics of server 1 error = %v", metrics[0].Err)
+	}
+	for _, name := range []string{SeriesNetRxBits, SeriesNetTxBits, SeriesNetRxPackets, SeriesNetTxPackets} {
+		if len(metrics[0].Data.Find(name)) == 0 {
+			t.Errorf("metrics of server 1 have no series %s", name)
+		}
+	}
+	if metrics[1].Err == nil || metrics[1].Data != nil {
+		t.Errorf("metrics of server 2 = %+v; want error without data", metrics[1])
+	}
+}
@@ -0,0 +1,110 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/netip"
+)
+
+// RdnsRecord is the reverse DNS entry of a single address.
+type RdnsRecord struct {
+	Ip string
+	// Type is either "ipv4" or "ipv6".
+	Type string
+	// Ptr is empty if no reverse DNS entry is set.
+	Ptr string
+	// Err is set if fetching the entry failed, Ptr is empty in this case.
+	Err error
+}
+
+// ServerRdnsInfo are the reverse DNS entries of all addresses of a server together with its hostname.
+type ServerRdnsInfo struct {
+	Hostname string
+	Records  []RdnsRecord
+}
+
+// ServerRdns are the reverse DNS entries of a single server.
+type ServerRdns = ServerResult[*ServerRdnsInfo]
+
+// CollectRdns fetches the reverse DNS entries of all IPv4 addresses of every server. As the API only knows
+// IPv6 networks, the entries of the configured IPv6 host addresses are fetched for the server whose network
+// contains them. An entry that cannot be fetched is returned with its error, so that the others are kept.
+// The servers fetched by the servers data group are reused, unless they are missing or outdated.
+func (c DefaultServerCollector) CollectRdns(ctx context.Context) ([]ServerRdns, error) {
+	return collectPerServer(ctx, c, "rdns", func(ctx context.Context, serverId int32) (*ServerRdnsInfo, error) {
+		server, err := c.getCachedServer(ctx, serverId)
+		if err != nil {
+			return nil, err
+		}
+		info := &ServerRdnsInfo{Hostname: deref(server.Hostname)}
+		if server.ServerLiveInfo == nil || server.ServerLiveInfo.Interfaces == nil {
+			return info, nil
+		}
+		for _, iface := range *server.ServerLiveInfo.Interfaces {
+			for _, ip := range deref(iface.Ipv4Addresses) {
+				ptr, err := c.getRdnsIpv4(ctx, ip)
+				info.Records = append(info.Records, newRdnsRecord(serverId, ip, "ipv4", ptr, err))
+			}
+			for _, addr := range addressesInPrefixes(c.rdnsIpv6Addresses, deref(iface.Ipv6NetworkPrefixes)) {
+				ptr, err := c.getRdnsIpv6(ctx, addr.String())
+				info.Records = append(info.Records, newRdnsRecord(serverId, addr.String(), "ipv6", ptr, err))
+			}
+		}
+		return info, nil
+	})
+}
+
+func newRdnsRecord(serverId int32, ip, ipType, ptr string, err error) RdnsRecord {
+	if err != nil {
+		slog.Warn("error getting rdns entry", "serverId", serverId, "ip", ip, "error", err)
+	}
+	return RdnsRecord{Ip: ip, Type: ipType, Ptr: ptr, Err: err}
+}
+
+// addressesInPrefixes returns the addresses contained in any of the given prefixes. Invalid prefixes are ignored.
+func addressesInPrefixes(addrs []netip.Addr, prefixes []string) []netip.Addr {
+	var contained []netip.Addr
+	for _, addr := range addrs {
+		for _, p := range prefixes {
+			prefix, err := netip.ParsePrefix(p)
+			if err != nil {
+				continue
+			}
+			if prefix.Contains(addr) {
+				contained = append(contained, addr)
+				break
+			}
+		}
+	}
+	return contained
+}
+
+func (c DefaultServerCollector) getRdnsIpv4(ctx context.Context, ip string) (string, error) {
+	resp, err := c.client.GetApiV1RdnsIpv4IpWithResponse(ctx, ip)
+	if err != nil {
+		return "", err
+	} else if resp.StatusCode() == http.StatusNotFound {
+		return "", nil
+	} else if resp.StatusCode() != http.StatusOK {
+		return "", errors.New("unexpected status code when getting rdns of ipv4 address: " + resp.Status())
+	} else if resp.JSON200 == nil {
+		return "", nil
+	}
+	return deref(resp.JSON200.Rdns), nil
+}
+
+func (c DefaultServerCollector) getRdnsIpv6(ctx context.Context, ip string) (string, error) {
+	resp, err := c.client.GetApiV1RdnsIpv6IpWithResponse(ctx, ip)
+	if err != nil {
+		return "", err
+	} else if resp.StatusCode() == http.StatusNotFound {
+		return "", nil
+	} else if resp.StatusCode() != http.StatusOK {
+		return "", errors.New("unexpected status code when getting rdns of ipv6 address: " + resp.Status())
+	} else if resp.JSON200 == nil {
+		return "", nil
+	}
+	return deref(resp.JSON200.Rdns), nil
+}
@@ -0,0 +1,97 @@
+package collector
+
+import (
+	"context"
+	"net/netip"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kodehat/netcupscp-exporter/internal/client"
+	"github.com/kodehat/netcupscp-exporter/internal/collector/collectortest"
+)
+
+func TestAddressesInPrefixes(t *testing.T) {
+	addrs := []netip.Addr{
+		netip.MustParseAddr("2a03:4000:1:2::1"),
+		netip.MustParseAddr("2a03:4000:1:2::25"),
+		netip.MustParseAddr("2a03:4000:9:9::1"),
+	}
+
+	tests := []struct {
+		name     string
+		prefixes []string
+		want     []netip.Addr
+	}{
+		{"matching prefix", []string{"2a03:4000:1:2::/64"}, addrs[:2]},
+		{"several prefixes", []string{"2a03:4000:9:9::/64", "2a03:4000:1:2::/64"}, addrs},
+		{"no matching prefix", []string{"2a03:4000:5:5::/64"}, nil},
+		{"invalid prefix", []string{"not-a-prefix"}, nil},
+		{"no prefixes", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := addressesInPrefixes(addrs, tt.prefixes); !slices.Equal(got, tt.want) {
+				t.Errorf("addressesInPrefixes() = %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollectRdnsKeepsEntriesIfOneFails(t *testing.T) {
+	hostname := "mail.example.com"
+	servers := collectortest.NewServers(1)
+	servers[0].Hostname = &hostname
+	servers[0].ServerLiveInfo = &client.ServerInfo{Interfaces: &[]client.ServerInterface{{Ipv4Addresses: &[]string{"192.0.2.1", "192.0.2.2"}}}}
+	// The entry of 192.0.2.2 fails to respond.
+	c := newTestCollector(t, &collectortest.API{Servers: servers, Rdns: map[string]string{"192.0.2.1": hostname}}, 0)
+
+	serverRdns, err := c.CollectRdns(context.Background())
+	if err != nil {
+		t.Fatalf("CollectRdns() error = %v", err)
+	}
+	if len(serverRdns) != 1 || serverRdns[0].Err != nil {
+		t.Fatalf("CollectRdns() = %+v; want a single successful server", serverRdns)
+	}
+	records := serverRdns[0].Data.Records
+	if len(records) != 2 {
+		t.Fatalf("records = %+v; want 2", records)
+	}
+	if records[0].Err != nil || records[0].Ptr != hostname {
+		t.Errorf("record of 192.0.2.1 = %+v; want ptr %s", records[0], hostname)
+	}
+	if records[1].Err == nil || records[1].Ptr != "" {
+		t.Errorf("record of 192.0.2.2 = %+v; want error without ptr", records[1])
+	}
+}
+
+func TestCollectRdnsReusesFetchedServers(t *testing.T) {
+	api := &collectortest.API{Servers: collectortest.NewServers(1, 2), Rdns: map[string]string{}}
+	c := newTestCollector(t, api, 0)
+
+	if _, err := c.CollectRdns(context.Background()); err != nil {
+		t.Fatalf("CollectRdns() error = %v", err)
+	}
+	if got := api.ServerRequests(); got != 2 {
+		t.Errorf("server requests without servers refresh = %d; want 2", got)
+	}
+
+	if _, err := c.CollectServerData(context.Background()); err != nil {
+		t.Fatalf("CollectServerData() error = %v", err)
+	}
+	if _, err := c.CollectRdns(context.Background()); err != nil {
+		t.Fatalf("CollectRdns() error = %v", err)
+	}
+	if got := api.ServerRequests(); got != 4 {
+		t.Errorf("server requests after servers refresh = %d; want 4", got)
+	}
+
+	c.servers.fetchedAt = c.servers.fetchedAt.Add(-maxCachedServerAge - time.Minute)
+	if _, err := c.CollectRdns(context.Background()); err != nil {
+		t.Fatalf("CollectRdns() error = %v", err)
+	}
+	if got := api.ServerRequests(); got != 6 {
+		t.Errorf("server requests with outdated servers = %d; want 6", got)
+	}
+}
@@ -0,0 +1,27 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/kodehat/netcupscp-exporter/internal/client"
+)
+
+// ServerSnapshots are the snapshots of a single server.
+type ServerSnapshots = ServerResult[[]client.SnapshotMinimal]
+
+// CollectSnapshots fetches the snapshots of every server.
+func (c DefaultServerCollector) CollectSnapshots(ctx context.Context) ([]ServerSnapshots, error) {
+	return collectPerServer(ctx, c, "snapshots", func(ctx context.Context, serverId int32) ([]client.SnapshotMinimal, error) {
+		resp, err := c.client.GetApiV1ServersServerIdSnapshotsWithResponse(ctx, serverId)
+		if err != nil {
+			return nil, err
+		} else if resp.StatusCode() != http.StatusOK {
+			return nil, errors.New("unexpected status code when getting snapshots: " + resp.Status())
+		} else if resp.JSON200 == nil {
+			return []client.SnapshotMinimal{}, nil
+		}
+		return *resp.JSON200, nil
+	})
+}
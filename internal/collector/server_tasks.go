@@ -0,0 +1,31 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/kodehat/netcupscp-exporter/internal/client"
+)
+
+// recentTasksLimit is the number of most recent tasks fetched per server.
+const recentTasksLimit int32 = 50
+
+// ServerTasks are the most recent tasks of a single server.
+type ServerTasks = ServerResult[[]client.TaskInfoMinimal]
+
+// CollectTasks fetches the most recent tasks of every server.
+func (c DefaultServerCollector) CollectTasks(ctx context.Context) ([]ServerTasks, error) {
+	return collectPerServer(ctx, c, "tasks", func(ctx context.Context, serverId int32) ([]client.TaskInfoMinimal, error) {
+		limit := recentTasksLimit
+		resp, err := c.client.GetApiV1TasksWithResponse(ctx, &client.GetApiV1TasksParams{ServerId: &serverId, Limit: &limit})
+		if err != nil {
+			return nil, err
+		} else if resp.StatusCode() != http.StatusOK {
+			return nil, errors.New("unexpected status code when getting tasks: " + resp.Status())
+		} else if resp.JSON200 == nil {
+			return []client.TaskInfoMinimal{}, nil
+		}
+		return *resp.JSON200, nil
+	})
+}
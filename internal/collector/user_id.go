@@ -0,0 +1,42 @@
+package collector
+
+import (
+	"fmt"
+	"sync"
+)
+
+// UserIdFunc returns the id of the user, which is required for user specific data.
+type UserIdFunc func() (int32, error)
+
+// userIdResolver resolves the user id on first use and remembers it once resolved, so that a user id
+// that is not available yet, e.g. as the access token could not be refreshed, is retried on the next use.
+type userIdResolver struct {
+	mu      sync.Mutex
+	resolve UserIdFunc
+	userId  int32
+}
+
+// available tells if the user id can be resolved at all.
+func (r *userIdResolver) available() bool {
+	return r.resolve != nil
+}
+
+// get returns the user id. Errors wrap ErrUserIdUnknown.
+func (r *userIdResolver) get() (int32, error) {
+	if r.resolve == nil {
+		return 0, ErrUserIdUnknown
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.userId != 0 {
+		return r.userId, nil
+	}
+	userId, err := r.resolve()
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrUserIdUnknown, err)
+	} else if userId == 0 {
+		return 0, ErrUserIdUnknown
+	}
+	r.userId = userId
+	return userId, nil
+}
@@ -0,0 +1,39 @@
+package collector
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUserIdResolverRetriesUntilResolved(t *testing.T) {
+	calls := 0
+	r := &userIdResolver{resolve: func() (int32, error) {
+		calls++
+		if calls == 1 {
+			return 0, errors.New("token unavailable")
+		}
+		return 7, nil
+	}}
+
+	if _, err := r.get(); !errors.Is(err, ErrUserIdUnknown) {
+		t.Errorf("get() error = %v; want %v", err, ErrUserIdUnknown)
+	}
+	for range 2 {
+		if got, err := r.get(); err != nil || got != 7 {
+			t.Errorf("get() = %v, %v; want 7", got, err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("resolve calls = %d; want 2", calls)
+	}
+}
+
+func TestUserIdResolverUnavailable(t *testing.T) {
+	r := &userIdResolver{}
+	if r.available() {
+		t.Error("available() = true; want false")
+	}
+	if _, err := r.get(); !errors.Is(err, ErrUserIdUnknown) {
+		t.Errorf("get() error = %v; want %v", err, ErrUserIdUnknown)
+	}
+}
@@ -0,0 +1,10 @@
+package collector
+
+// deref returns the value the given pointer points to or the zero value if it is nil.
+func deref[T any](p *T) T {
+	var zero T
+	if p == nil {
+		return zero
+	}
+	return *p
+}
@@ -0,0 +1,26 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/kodehat/netcupscp-exporter/internal/client"
+)
+
+// CollectVlans fetches the VLANs of the user.
+func (c DefaultServerCollector) CollectVlans(ctx context.Context) ([]client.VLan, error) {
+	userId, err := c.userId.get()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.GetApiV1UsersUserIdVlansWithResponse(ctx, userId, &client.GetApiV1UsersUserIdVlansParams{})
+	if err != nil {
+		return nil, err
+	} else if resp.StatusCode() != http.StatusOK {
+		return nil, errors.New("unexpected status code when getting vlans: " + resp.Status())
+	} else if resp.JSON200 == nil {
+		return []client.VLan{}, nil
+	}
+	return *resp.JSON200, nil
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// DefaultAccountName is the name of the account that is configured using flags only.
+const DefaultAccountName = "default"
+
+// Account is a single Netcup SCP account to scrape.
+type Account struct {
+	// Name is used as value of the "account" label of all metrics of this account.
+	Name string `json:"name"`
+	// RefreshToken can be empty, in which case new device authorization flow will be used.
+	RefreshToken string `json:"refreshToken"`
+	// TokenFile is optional and used to persist rotated refresh tokens of this account.
+	TokenFile string `json:"tokenFile"`
+	// UserId is optional and overrides the user id taken from the access token.
+	UserId int32 `json:"userId"`
+}
+
+type Config struct {
+	Accounts []Account `json:"accounts"`
+}
+
+// Load reads the JSON config file at the given path and validates it.
+func Load(path string) (*Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config Config
+	if err := json.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("unable to parse config file: %w", err)
+	}
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+func (c Config) validate() error {
+	if len(c.Accounts) == 0 {
+		return errors.New("config file contains no accounts")
+	}
+	names := make(map[string]bool, len(c.Accounts))
+	tokenFiles := make(map[string]bool, len(c.Accounts))
+	for _, account := range c.Accounts {
+		if account.Name == "" {
+			return errors.New("config file contains an account without name")
+		}
+		if names[account.Name] {
+			return fmt.Errorf("config file contains account %q more than once", account.Name)
+		}
+		names[account.Name] = true
+		if account.TokenFile != "" {
+			if tokenFiles[account.TokenFile] {
+				return fmt.Errorf("config file uses token file %q for more than one account", account.TokenFile)
+			}
+			tokenFiles[account.TokenFile] = true
+		}
+	}
+	return nil
+}
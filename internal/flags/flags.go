@@ -5,22 +5,57 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"net/netip"
+	"strings"
+	"time"
 )
 
 const (
 	envHost         = "HOST"
 	envPort         = "PORT"
 	envRefreshToken = "REFRESH_TOKEN"
+	envTokenFile    = "TOKEN_FILE"
+	envConfigFile   = "CONFIG_FILE"
+	envUserId       = "USER_ID"
+	envWorkers      = "COLLECTOR_WORKERS"
+	envInterval     = "REFRESH_INTERVAL"
+	envIntervals    = "REFRESH_INTERVALS"
+	envJitter       = "REFRESH_JITTER"
+	envRetries      = "RETRY_MAX"
+	envRetryBudget  = "RETRY_BUDGET"
+	envHours        = "METRICS_HOURS"
+	envRdnsIpv6     = "RDNS_IPV6_ADDRESSES"
+	envLokiUrl      = "LOG_SHIPPER_LOKI_URL"
+	envLogFile      = "LOG_SHIPPER_FILE"
+	envCursorFile   = "LOG_SHIPPER_CURSOR_FILE"
+	envLogInterval  = "LOG_SHIPPER_INTERVAL"
+	envBackfill     = "METRICS_BACKFILL"
 	envLogLevel     = "LOG_LEVEL"
 	envLogJson      = "LOG_JSON"
 )
 
 type Flags struct {
-	Host         string
-	Port         string
-	RefreshToken string
-	logLevel     string
-	logJson      bool
+	Host             string
+	Port             string
+	RefreshToken     string
+	TokenFile        string
+	ConfigFile       string
+	UserId           int
+	Workers          int
+	RefreshInterval  time.Duration
+	RefreshJitter    time.Duration
+	RetryMax         int
+	RetryBudget      int
+	MetricsHours     int
+	MetricsBackfill  bool
+	LokiUrl          string
+	LogFile          string
+	CursorFile       string
+	LogInterval      time.Duration
+	logLevel         string
+	logJson          bool
+	refreshIntervals string
+	rdnsIpv6         string
 }
 
 var F Flags
@@ -30,6 +65,25 @@ func Load() {
 	host := getenvOrDefault(envHost, "")
 	port := getenvOrDefault(envPort, "2008")
 	refreshToken := getenvOrDefault(envRefreshToken, "")
+	tokenFile := getenvOrDefault(envTokenFile, "")
+	configFile := getenvOrDefault(envConfigFile, "")
+	userId := getenvIntOrDefault(envUserId, 0)
+	workers := getenvIntOrDefault(envWorkers, 4)
+	refreshInterval := getenvDurationOrDefault(envInterval, 30*time.Second)
+	refreshIntervals := getenvOrDefault(envIntervals, "")
+	refreshJitter := getenvDurationOrDefault(envJitter, 5*time.Second)
+	retryMax := getenvIntOrDefault(envRetries, 3)
+	retryBudget := getenvIntOrDefault(envRetryBudget, 20)
+	metricsHours := getenvIntOrDefault(envHours, 1)
+	rdnsIpv6 := getenvOrDefault(envRdnsIpv6, "")
+	lokiUrl := getenvOrDefault(envLokiUrl, "")
+	logFile := getenvOrDefault(envLogFile, "")
+	cursorFile := getenvOrDefault(envCursorFile, "")
+	logInterval := getenvDurationOrDefault(envLogInterval, time.Minute)
+	metricsBackfill := false
+	if getenvOrDefault(envBackfill, "false") == "true" {
+		metricsBackfill = true
+	}
 	logLevel := getenvOrDefault(envLogLevel, "info")
 	logJson := false
 	if getenvOrDefault(envLogJson, "false") == "true" {
@@ -41,6 +95,22 @@ func Load() {
 	flag.StringVar(&flags.Host, "host", host, "Set host to bind the HTTP server to (default: all interfaces).")
 	flag.StringVar(&flags.Port, "port", port, "Set port to bind the HTTP server to (default: 2008).")
 	flag.StringVar(&flags.RefreshToken, "refresh-token", refreshToken, "Set Netcup SCP refresh token for authentication. Can be ommitted for first time setup.")
+	flag.StringVar(&flags.TokenFile, "token-file", tokenFile, "Set file to persist rotated refresh tokens in. A token stored in this file takes precedence over the refresh token flag.")
+	flag.StringVar(&flags.ConfigFile, "config-file", configFile, "Set JSON config file listing several accounts to scrape. Refresh token and token file flags are ignored if set.")
+	flag.IntVar(&flags.UserId, "user-id", userId, "Set Netcup SCP user id used for user specific data like failover IPs (default: taken from the access token).")
+	flag.IntVar(&flags.Workers, "collector-workers", workers, "Set number of servers fetched concurrently per account (default: 4).")
+	flag.DurationVar(&flags.RefreshInterval, "refresh-interval", refreshInterval, "Set base interval data groups are refreshed in (default: 30s).")
+	flag.StringVar(&flags.refreshIntervals, "refresh-intervals", refreshIntervals, "Set comma separated intervals of single data groups overriding the base and default intervals (e.g. servers=1m), 0 disables a data group.")
+	flag.DurationVar(&flags.RefreshJitter, "refresh-jitter", refreshJitter, "Set maximum random delay added to every refresh (default: 5s).")
+	flag.IntVar(&flags.RetryMax, "retry-max", retryMax, "Set maximum number of retries of a single failed request (default: 3).")
+	flag.IntVar(&flags.RetryBudget, "retry-budget", retryBudget, "Set maximum number of retries of all requests during a single refresh (default: 20).")
+	flag.IntVar(&flags.MetricsHours, "metrics-hours", metricsHours, "Set number of hours of CPU, disk and network metric series requested per server (default: 1).")
+	flag.BoolVar(&flags.MetricsBackfill, "metrics-backfill", metricsBackfill, "Enable serving all samples of metric series with their original timestamps on /metrics/backfill.")
+	flag.StringVar(&flags.rdnsIpv6, "rdns-ipv6-addresses", rdnsIpv6, "Set comma separated IPv6 host addresses whose reverse DNS entries are checked.")
+	flag.StringVar(&flags.LokiUrl, "log-shipper-loki-url", lokiUrl, "Set Loki push API url to ship server and account logs to (e.g. http://loki:3100/loki/api/v1/push).")
+	flag.StringVar(&flags.LogFile, "log-shipper-file", logFile, "Set file to append server and account logs to as JSON lines, use - for stderr.")
+	flag.StringVar(&flags.CursorFile, "log-shipper-cursor-file", cursorFile, "Set file to persist the position of shipped logs in, so that they are not shipped again after a restart.")
+	flag.DurationVar(&flags.LogInterval, "log-shipper-interval", logInterval, "Set interval new logs are shipped in (default: 1m).")
 	flag.StringVar(&flags.logLevel, "log-level", logLevel, "Set logging level (debug, info, warn, error).")
 	flag.BoolVar(&flags.logJson, "log-json", logJson, "Enable JSON formatted logging.")
 	flag.Parse()
@@ -55,6 +125,49 @@ func (f Flags) GetLogLevel() (slog.Level, error) {
 	return level, err
 }
 
+// GetRefreshIntervals returns the intervals of single data groups, keyed by data group name.
+// An interval of zero disables the data group.
+func (f Flags) GetRefreshIntervals() (map[string]time.Duration, error) {
+	intervals := map[string]time.Duration{}
+	if strings.TrimSpace(f.refreshIntervals) == "" {
+		return intervals, nil
+	}
+	for entry := range strings.SplitSeq(f.refreshIntervals, ",") {
+		group, value, found := strings.Cut(strings.TrimSpace(entry), "=")
+		if !found {
+			return nil, fmt.Errorf("invalid refresh interval %q, expected <group>=<duration>", entry)
+		}
+		interval, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid refresh interval of data group %s: %w", group, err)
+		}
+		if interval < 0 {
+			return nil, fmt.Errorf("refresh interval of data group %s must not be negative", group)
+		}
+		intervals[group] = interval
+	}
+	return intervals, nil
+}
+
+// GetRdnsIpv6Addresses returns the IPv6 host addresses whose reverse DNS entries are checked.
+func (f Flags) GetRdnsIpv6Addresses() ([]netip.Addr, error) {
+	var addrs []netip.Addr
+	if strings.TrimSpace(f.rdnsIpv6) == "" {
+		return addrs, nil
+	}
+	for entry := range strings.SplitSeq(f.rdnsIpv6, ",") {
+		addr, err := netip.ParseAddr(strings.TrimSpace(entry))
+		if err != nil {
+			return nil, fmt.Errorf("invalid rdns ipv6 address: %w", err)
+		}
+		if !addr.Is6() || addr.Is4In6() {
+			return nil, fmt.Errorf("rdns ipv6 address %s is no IPv6 address", addr)
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
 func (f Flags) GetLogHandler(w io.Writer) slog.Handler {
 	logLevel, err := f.GetLogLevel()
 	if err != nil {
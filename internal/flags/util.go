@@ -1,6 +1,11 @@
 package flags
 
-import "os"
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
 
 func getenvOrDefault(envVar, defaultValue string) string {
 	value := os.Getenv(envVar)
@@ -9,3 +14,27 @@ func getenvOrDefault(envVar, defaultValue string) string {
 	}
 	return value
 }
+
+func getenvIntOrDefault(envVar string, defaultValue int) int {
+	value := os.Getenv(envVar)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		panic(fmt.Errorf("unable to parse environment variable %s: %w\n", envVar, err))
+	}
+	return parsed
+}
+
+func getenvDurationOrDefault(envVar string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(envVar)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		panic(fmt.Errorf("unable to parse environment variable %s: %w\n", envVar, err))
+	}
+	return parsed
+}
@@ -0,0 +1,85 @@
+package login
+
+import (
+	"html/template"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/kodehat/netcupscp-exporter/internal/authenticator"
+)
+
+var loginTemplate = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>netcupscp-exporter login</title>
+</head>
+<body>
+<h1>netcupscp-exporter login</h1>
+{{- range . }}
+<h2>Account {{ .Name }}</h2>
+{{- if .DeviceAuth }}
+<p>Open <a href="{{ .DeviceAuth.VerificationURIComplete }}" target="_blank" rel="noopener">{{ .DeviceAuth.VerificationURI }}</a> and enter the following code:</p>
+<p><code>{{ .DeviceAuth.UserCode }}</code></p>
+{{- if not .DeviceAuth.Expiry.IsZero }}
+<p>The code expires at {{ .DeviceAuth.Expiry.Format "2006-01-02 15:04:05 MST" }}.</p>
+{{- end }}
+{{- else if .Authenticated }}
+<p>The account is authenticated.</p>
+{{- else }}
+<p>No device authorization is pending.</p>
+{{- end }}
+{{- end }}
+</body>
+</html>
+`))
+
+// Account is an authenticator that is shown on the login page using the given name.
+type Account struct {
+	Name          string
+	Authenticator authenticator.Authenticator
+}
+
+type accountStatus struct {
+	Name          string
+	DeviceAuth    *deviceAuth
+	Authenticated bool
+}
+
+type deviceAuth struct {
+	VerificationURI         string
+	VerificationURIComplete string
+	UserCode                string
+	Expiry                  time.Time
+}
+
+// NewHandler returns a handler showing the verification uri and user code of pending
+// device authorizations, so that first-time setup can be completed without reading the logs.
+func NewHandler(accounts []Account) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := make([]accountStatus, len(accounts))
+		for i, account := range accounts {
+			page[i] = accountStatus{
+				Name:          account.Name,
+				Authenticated: account.Authenticator.GetAuthenticatedClient() != nil,
+			}
+			if da := account.Authenticator.DeviceAuthorization(); da != nil {
+				page[i].DeviceAuth = &deviceAuth{
+					VerificationURI:         da.VerificationURI,
+					VerificationURIComplete: da.VerificationURI,
+					UserCode:                da.UserCode,
+					Expiry:                  da.Expiry,
+				}
+				if da.VerificationURIComplete != "" {
+					page[i].DeviceAuth.VerificationURIComplete = da.VerificationURIComplete
+				}
+			}
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := loginTemplate.Execute(w, page); err != nil {
+			slog.Error("error rendering login page", "error", err)
+		}
+	})
+}
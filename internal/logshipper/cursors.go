@@ -0,0 +1,132 @@
+package logshipper
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Cursor is the position of a single log stream. As several entries may share a time and the
+// API does not return ids, it remembers the ids of the shipped entries dated exactly Time.
+type Cursor struct {
+	// Time is the time of the last shipped entry, which is zero if nothing has been shipped yet.
+	Time time.Time `json:"time"`
+	// Shipped are the ids of the shipped entries dated Time, see entryId.
+	Shipped []string `json:"shipped,omitempty"`
+}
+
+// UnmarshalJSON also accepts a plain time, which is how cursors were stored before.
+func (c *Cursor) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		*c = Cursor{}
+		return json.Unmarshal(data, &c.Time)
+	}
+	type cursor Cursor
+	return json.Unmarshal(data, (*cursor)(c))
+}
+
+// IsShipped tells if the entry with the given time and id has been shipped already.
+func (c Cursor) IsShipped(t time.Time, id string) bool {
+	return t.Before(c.Time) || t.Equal(c.Time) && slices.Contains(c.Shipped, id)
+}
+
+// advance returns the cursor after shipping the entry with the given time and id.
+func (c Cursor) advance(t time.Time, id string) Cursor {
+	if t.After(c.Time) {
+		return Cursor{Time: t, Shipped: []string{id}}
+	} else if t.Equal(c.Time) && !slices.Contains(c.Shipped, id) {
+		return Cursor{Time: t, Shipped: append(slices.Clip(c.Shipped), id)}
+	}
+	return c
+}
+
+// entryId identifies a log entry by hashing all of its fields.
+func entryId(entry Entry) string {
+	content, _ := json.Marshal(entry)
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Cursors remember the position of every log stream. If a file is set, the cursors are persisted
+// in it, so that entries are not shipped again after a restart. Cursors are safe for concurrent
+// use by the log shippers of several accounts.
+type Cursors struct {
+	mu      sync.Mutex
+	path    string
+	cursors map[string]Cursor
+}
+
+// NewCursors creates cursors persisted in the file at the given path, which is optional.
+// Cursors stored in an existing file are loaded right away.
+func NewCursors(path string) (*Cursors, error) {
+	c := &Cursors{
+		path:    path,
+		cursors: map[string]Cursor{},
+	}
+	if path == "" {
+		return c, nil
+	}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, &c.cursors); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Get returns the cursor of the stream, which is zero if nothing has been shipped yet.
+func (c *Cursors) Get(stream string) Cursor {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cursors[stream]
+}
+
+// Advance moves the cursors of the given streams forward and persists all cursors. A cursor
+// replaces the current one of its stream unless it is older.
+func (c *Cursors) Advance(cursors map[string]Cursor) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for stream, cursor := range cursors {
+		if !cursor.Time.Before(c.cursors[stream].Time) {
+			c.cursors[stream] = cursor
+		}
+	}
+	if c.path == "" {
+		return nil
+	}
+	return c.save()
+}
+
+// save writes the cursors to a temporary file first and renames it afterwards,
+// so that a crash while writing never leaves a truncated file behind.
+func (c *Cursors) save() error {
+	content, err := json.Marshal(c.cursors)
+	if err != nil {
+		return err
+	}
+	tmpFile, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(content); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), c.path)
+}
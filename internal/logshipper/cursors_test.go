@@ -0,0 +1,77 @@
+package logshipper
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCursorsPersistAndOnlyMoveForward(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cursors.json")
+	cursors, err := NewCursors(path)
+	if err != nil {
+		t.Fatalf("NewCursors() error = %v", err)
+	}
+
+	later := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	if err := cursors.Advance(map[string]Cursor{"a/server/v1": {Time: later, Shipped: []string{"x", "y"}}}); err != nil {
+		t.Fatalf("Advance() error = %v", err)
+	}
+	if err := cursors.Advance(map[string]Cursor{"a/server/v1": {Time: later.Add(-time.Hour), Shipped: []string{"z"}}}); err != nil {
+		t.Fatalf("Advance() error = %v", err)
+	}
+
+	reloaded, err := NewCursors(path)
+	if err != nil {
+		t.Fatalf("NewCursors() error = %v", err)
+	}
+	if got := reloaded.Get("a/server/v1"); !got.Time.Equal(later) || !slices.Equal(got.Shipped, []string{"x", "y"}) {
+		t.Errorf("Get() = %+v; want time %v with shipped x and y", got, later)
+	}
+	if got := reloaded.Get("a/account"); !got.Time.IsZero() || got.Shipped != nil {
+		t.Errorf("Get() = %+v; want zero cursor", got)
+	}
+}
+
+func TestCursorsLoadPlainTimes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cursors.json")
+	if err := os.WriteFile(path, []byte(`{"a/server/v1":"2025-01-01T12:00:00Z"}`), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	cursors, err := NewCursors(path)
+	if err != nil {
+		t.Fatalf("NewCursors() error = %v", err)
+	}
+	want := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	if got := cursors.Get("a/server/v1"); !got.Time.Equal(want) || got.Shipped != nil {
+		t.Errorf("Get() = %+v; want time %v without shipped entries", got, want)
+	}
+}
+
+func TestCursorIsShipped(t *testing.T) {
+	at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	cursor := Cursor{}.advance(at, "x")
+
+	tests := []struct {
+		name string
+		t    time.Time
+		id   string
+		want bool
+	}{
+		{"older", at.Add(-time.Second), "y", true},
+		{"shipped at cursor time", at, "x", true},
+		{"other at cursor time", at, "y", false},
+		{"newer", at.Add(time.Second), "x", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cursor.IsShipped(tt.t, tt.id); got != tt.want {
+				t.Errorf("IsShipped() = %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
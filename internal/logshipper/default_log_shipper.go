@@ -0,0 +1,145 @@
+package logshipper
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kodehat/netcupscp-exporter/internal/client"
+	"github.com/kodehat/netcupscp-exporter/internal/collector"
+)
+
+type DefaultLogShipper struct {
+	account   string
+	collector collector.ServerCollector
+	sink      Sink
+	cursors   *Cursors
+	interval  time.Duration
+	jitter    time.Duration
+}
+
+var _ LogShipper = DefaultLogShipper{}
+
+// NewDefaultLogShipper creates a new DefaultLogShipper that ships the new log entries of the given account
+// to the sink in the given interval. Like refreshes, every run is delayed by a random duration up to the given jitter.
+func NewDefaultLogShipper(account string, collector collector.ServerCollector, sink Sink, cursors *Cursors, interval, jitter time.Duration) DefaultLogShipper {
+	return DefaultLogShipper{
+		account:   account,
+		collector: collector,
+		sink:      sink,
+		cursors:   cursors,
+		interval:  interval,
+		jitter:    jitter,
+	}
+}
+
+// streamKey returns the key of the stream's cursor, which is unique across accounts.
+func (ls DefaultLogShipper) streamKey(stream collector.LogStream) string {
+	if stream.IsAccount() {
+		return ls.account + "/account"
+	}
+	return ls.account + "/server/" + stream.ServerName
+}
+
+// ship pushes all entries not shipped yet to the sink and advances the cursors afterwards.
+// Streams that could not be fetched are shipped on the next run.
+func (ls DefaultLogShipper) ship(ctx context.Context) error {
+	streamLogs, err := ls.collector.CollectLogs(ctx, func(stream collector.LogStream) time.Time {
+		return ls.cursors.Get(ls.streamKey(stream)).Time
+	})
+	if err != nil {
+		return err
+	}
+
+	var entries []Entry
+	cursors := map[string]Cursor{}
+	for _, sl := range streamLogs {
+		if sl.Err != nil {
+			continue
+		}
+		key := ls.streamKey(sl.Stream)
+		shipped := ls.cursors.Get(key)
+		cursor := shipped
+		for _, log := range sl.Logs {
+			entry := ls.newEntry(sl.Stream, log)
+			id := entryId(entry)
+			if shipped.IsShipped(entry.Time, id) {
+				continue
+			}
+			entries = append(entries, entry)
+			cursor = cursor.advance(entry.Time, id)
+			cursors[key] = cursor
+		}
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	slices.SortStableFunc(entries, func(a, b Entry) int {
+		return a.Time.Compare(b.Time)
+	})
+
+	if err := ls.sink.Push(ctx, entries); err != nil {
+		return err
+	}
+	slog.Debug("shipped log entries", "account", ls.account, "entries", len(entries))
+	return ls.cursors.Advance(cursors)
+}
+
+func (ls DefaultLogShipper) newEntry(stream collector.LogStream, log client.Log) Entry {
+	entry := Entry{
+		Time:       *log.Date,
+		Account:    ls.account,
+		ServerName: stream.ServerName,
+	}
+	if log.Type != nil {
+		entry.Type = string(*log.Type)
+	}
+	if log.LogKey != nil {
+		entry.LogKey = *log.LogKey
+	}
+	if log.Message != nil {
+		entry.Message = *log.Message
+	}
+	if user := log.ExecutingUser; user != nil {
+		var name []string
+		for _, part := range []*string{user.Firstname, user.Lastname} {
+			if part != nil && *part != "" {
+				name = append(name, *part)
+			}
+		}
+		entry.ExecutingUser = strings.Join(name, " ")
+		if entry.ExecutingUser == "" && user.Email != nil {
+			entry.ExecutingUser = *user.Email
+		}
+	}
+	return entry
+}
+
+// randomJitter returns a random duration between zero and the configured jitter.
+func (ls DefaultLogShipper) randomJitter() time.Duration {
+	if ls.jitter <= 0 {
+		return 0
+	}
+	return rand.N(ls.jitter)
+}
+
+func (ls DefaultLogShipper) StartShippingPeriodically(ctx context.Context) {
+	slog.Info("starting periodic log shipping", "account", ls.account, "interval", ls.interval.String())
+	timer := time.NewTimer(ls.randomJitter()) // Run once almost immediately.
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			if err := ls.ship(ctx); err != nil {
+				slog.Warn("error while shipping logs", "account", ls.account, "error", err)
+			}
+			timer.Reset(ls.interval + ls.randomJitter())
+		case <-ctx.Done():
+			slog.Debug("stopping log shipping", "account", ls.account)
+			return
+		}
+	}
+}
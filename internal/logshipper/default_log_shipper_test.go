@@ -0,0 +1,135 @@
+package logshipper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kodehat/netcupscp-exporter/internal/client"
+	"github.com/kodehat/netcupscp-exporter/internal/collector"
+	"github.com/kodehat/netcupscp-exporter/internal/collector/collectortest"
+)
+
+// newTestCollector returns a collector fetching from a fake API serving the given data.
+func newTestCollector(t *testing.T, api *collectortest.API) collector.ServerCollector {
+	t.Helper()
+	server := collectortest.NewServer(t, api)
+	c, err := collector.NewDefaultServerCollector(collectortest.Doer(server), 2, nil, nil)
+	if err != nil {
+		t.Fatalf("NewDefaultServerCollector() error = %v", err)
+	}
+	return c
+}
+
+// recordingSink remembers all pushed entries and fails with err if set.
+type recordingSink struct {
+	pushes [][]Entry
+	err    error
+}
+
+func (s *recordingSink) Push(_ context.Context, entries []Entry) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.pushes = append(s.pushes, entries)
+	return nil
+}
+
+func newTestLogs(dates ...time.Time) []client.Log {
+	logs := make([]client.Log, len(dates))
+	for i, date := range dates {
+		message := fmt.Sprintf("entry %d", i)
+		logs[i] = client.Log{Date: &date, Message: &message}
+	}
+	return logs
+}
+
+func TestShipAdvancesCursorsOfShippedStreams(t *testing.T) {
+	newest := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	// Server 2 fails to respond, the account logs are left out without a user id.
+	c := newTestCollector(t, &collectortest.API{
+		Servers:    collectortest.NewServers(1, 2),
+		ServerLogs: map[int32][]client.Log{1: newTestLogs(newest, newest.Add(-time.Minute), newest.Add(-2*time.Minute))},
+	})
+	cursors, _ := NewCursors("")
+	sink := &recordingSink{}
+	ls := NewDefaultLogShipper("a", c, sink, cursors, time.Minute, 0)
+
+	if err := ls.ship(context.Background()); err != nil {
+		t.Fatalf("ship() error = %v", err)
+	}
+	if len(sink.pushes) != 1 || len(sink.pushes[0]) != 3 {
+		t.Fatalf("pushes = %+v; want one push of 3 entries", sink.pushes)
+	}
+	for i, entry := range sink.pushes[0] {
+		if want := newest.Add(time.Duration(i-2) * time.Minute); !entry.Time.Equal(want) || entry.ServerName != "v1" {
+			t.Errorf("entry %d = %+v; want time %v of server v1", i, entry, want)
+		}
+	}
+	if got := cursors.Get("a/server/v1"); !got.Time.Equal(newest) || len(got.Shipped) != 1 {
+		t.Errorf("cursor of server v1 = %+v; want time %v with one shipped entry", got, newest)
+	}
+	for _, key := range []string{"a/server/v2", "a/account"} {
+		if got := cursors.Get(key).Time; !got.IsZero() {
+			t.Errorf("cursor of %s = %v; want zero time", key, got)
+		}
+	}
+
+	// Nothing is shipped again.
+	if err := ls.ship(context.Background()); err != nil {
+		t.Fatalf("ship() error = %v", err)
+	}
+	if len(sink.pushes) != 1 {
+		t.Errorf("pushes = %d; want 1", len(sink.pushes))
+	}
+}
+
+func TestShipKeepsCursorsIfPushFails(t *testing.T) {
+	newest := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := newTestCollector(t, &collectortest.API{Servers: collectortest.NewServers(1), ServerLogs: map[int32][]client.Log{1: newTestLogs(newest)}})
+	cursors, _ := NewCursors("")
+	sink := &recordingSink{err: errors.New("sink unavailable")}
+	ls := NewDefaultLogShipper("a", c, sink, cursors, time.Minute, 0)
+
+	if err := ls.ship(context.Background()); err == nil {
+		t.Fatal("ship() error = nil; want error")
+	}
+	if got := cursors.Get("a/server/v1").Time; !got.IsZero() {
+		t.Errorf("cursor of server v1 = %v; want zero time", got)
+	}
+}
+
+func TestShipEntriesSharingTheCursorTime(t *testing.T) {
+	newest := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	api := &collectortest.API{
+		Servers:    collectortest.NewServers(1),
+		ServerLogs: map[int32][]client.Log{1: newTestLogs(newest, newest, newest.Add(-time.Minute))},
+	}
+	c := newTestCollector(t, api)
+	cursors, _ := NewCursors("")
+	sink := &recordingSink{}
+	ls := NewDefaultLogShipper("a", c, sink, cursors, time.Minute, 0)
+
+	if err := ls.ship(context.Background()); err != nil {
+		t.Fatalf("ship() error = %v", err)
+	}
+	// Another entry shows up with the time of the last shipped ones.
+	late, message := newTestLogs(newest)[0], "late entry"
+	late.Message = &message
+	api.ServerLogs[1] = append([]client.Log{late}, api.ServerLogs[1]...)
+	if err := ls.ship(context.Background()); err != nil {
+		t.Fatalf("ship() error = %v", err)
+	}
+
+	if len(sink.pushes) != 2 || len(sink.pushes[0]) != 3 || len(sink.pushes[1]) != 1 {
+		t.Fatalf("pushes = %+v; want a push of 3 entries and one of the late entry", sink.pushes)
+	}
+	if got := sink.pushes[1][0]; got.Message != message || !got.Time.Equal(newest) {
+		t.Errorf("entry = %+v; want the late entry", got)
+	}
+	if got := cursors.Get("a/server/v1"); len(got.Shipped) != 3 {
+		t.Errorf("cursor of server v1 = %+v; want 3 shipped entries", got)
+	}
+}
@@ -0,0 +1,34 @@
+package logshipper
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// JsonLinesSink writes every entry as a single line of JSON.
+type JsonLinesSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+var _ Sink = &JsonLinesSink{}
+
+func NewJsonLinesSink(w io.Writer) *JsonLinesSink {
+	return &JsonLinesSink{
+		w: w,
+	}
+}
+
+func (s *JsonLinesSink) Push(_ context.Context, entries []Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	enc := json.NewEncoder(s.w)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package logshipper
+
+import (
+	"context"
+	"time"
+)
+
+// Entry is a single audit log entry of a server or of an account.
+type Entry struct {
+	Time    time.Time `json:"time"`
+	Account string    `json:"account"`
+	// ServerName is empty for entries of the account itself.
+	ServerName    string `json:"servername,omitempty"`
+	Type          string `json:"type,omitempty"`
+	LogKey        string `json:"logKey,omitempty"`
+	Message       string `json:"message,omitempty"`
+	ExecutingUser string `json:"executingUser,omitempty"`
+}
+
+// Sink receives shipped log entries.
+type Sink interface {
+	// Push delivers the given entries, which are ordered by time. Entries are shipped
+	// again on the next run if an error is returned.
+	Push(context.Context, []Entry) error
+}
+
+type LogShipper interface {
+	StartShippingPeriodically(context.Context)
+}
@@ -0,0 +1,103 @@
+package logshipper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// lokiJob is the value of the "job" label of all pushed streams.
+const lokiJob = "netcupscp-exporter"
+
+// LokiSink pushes entries to the push API of Loki (or any compatible endpoint) as JSON.
+// Entries are grouped into streams labeled by account, server and log type.
+type LokiSink struct {
+	url    string
+	client *http.Client
+}
+
+var _ Sink = LokiSink{}
+
+// NewLokiSink creates a new LokiSink pushing to the given url, e.g. http://loki:3100/loki/api/v1/push.
+func NewLokiSink(url string, client *http.Client) LokiSink {
+	return LokiSink{
+		url:    url,
+		client: client,
+	}
+}
+
+type lokiPushRequest struct {
+	Streams []lokiStream `json:"streams"`
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// lokiLine is the log line of an entry. Labels of the stream are left out.
+type lokiLine struct {
+	LogKey        string `json:"logKey,omitempty"`
+	Message       string `json:"message,omitempty"`
+	ExecutingUser string `json:"executingUser,omitempty"`
+}
+
+func (s LokiSink) Push(ctx context.Context, entries []Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(buildLokiPushRequest(entries))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status code when pushing logs: %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// buildLokiPushRequest groups the entries into streams, keeping their order within every stream.
+func buildLokiPushRequest(entries []Entry) lokiPushRequest {
+	var request lokiPushRequest
+	streamIndex := map[string]int{}
+	for _, entry := range entries {
+		labels := map[string]string{
+			"job":     lokiJob,
+			"account": entry.Account,
+		}
+		if entry.ServerName != "" {
+			labels["servername"] = entry.ServerName
+		}
+		if entry.Type != "" {
+			labels["level"] = strings.ToLower(entry.Type)
+		}
+		key := entry.Account + "\xff" + entry.ServerName + "\xff" + entry.Type
+		i, ok := streamIndex[key]
+		if !ok {
+			i = len(request.Streams)
+			streamIndex[key] = i
+			request.Streams = append(request.Streams, lokiStream{Stream: labels})
+		}
+
+		// Marshaling plain strings cannot fail.
+		line, _ := json.Marshal(lokiLine{LogKey: entry.LogKey, Message: entry.Message, ExecutingUser: entry.ExecutingUser})
+		request.Streams[i].Values = append(request.Streams[i].Values, [2]string{strconv.FormatInt(entry.Time.UnixNano(), 10), string(line)})
+	}
+	return request
+}
@@ -0,0 +1,62 @@
+package logshipper
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLokiSinkPushGroupsStreams(t *testing.T) {
+	var got lokiPushRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q; want application/json", ct)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("Decode() error = %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	entries := []Entry{
+		{Time: time.Unix(1, 0), Account: "a", ServerName: "v1", Type: "INFO", Message: "started"},
+		{Time: time.Unix(2, 0), Account: "a", Type: "WARNING", Message: "login"},
+		{Time: time.Unix(3, 0), Account: "a", ServerName: "v1", Type: "INFO", Message: "stopped"},
+	}
+	if err := NewLokiSink(server.URL, server.Client()).Push(context.Background(), entries); err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+
+	if n := len(got.Streams); n != 2 {
+		t.Fatalf("len(Streams) = %d; want 2", n)
+	}
+	first := got.Streams[0]
+	if first.Stream["servername"] != "v1" || first.Stream["level"] != "info" || first.Stream["job"] != lokiJob {
+		t.Errorf("Streams[0].Stream = %v; want servername v1 and level info", first.Stream)
+	}
+	if n := len(first.Values); n != 2 {
+		t.Fatalf("len(Streams[0].Values) = %d; want 2", n)
+	}
+	if ts := first.Values[1][0]; ts != "3000000000" {
+		t.Errorf("Streams[0].Values[1] timestamp = %s; want 3000000000", ts)
+	}
+	if _, ok := got.Streams[1].Stream["servername"]; ok {
+		t.Errorf("Streams[1].Stream = %v; want no servername for account logs", got.Streams[1].Stream)
+	}
+}
+
+func TestLokiSinkPushFails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "entry too far behind", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	err := NewLokiSink(server.URL, server.Client()).Push(context.Background(), []Entry{{Time: time.Unix(1, 0), Account: "a"}})
+	if err == nil {
+		t.Errorf("Push() error = nil; want error")
+	}
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// BackfillHandler serves the historic samples of all snapshots with their original timestamps
+// in the OpenMetrics text format, which can be imported using "promtool tsdb create-blocks-from openmetrics".
+// Unlike a registry, it serves several samples of the same series.
+func BackfillHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		families := exporter.backfillFamilies()
+		format := expfmt.NewFormat(expfmt.TypeOpenMetrics)
+		w.Header().Set("Content-Type", string(format))
+		enc := expfmt.NewEncoder(w, format)
+		for _, family := range families {
+			if err := enc.Encode(family); err != nil {
+				slog.Error("error encoding backfill samples", "metric", family.GetName(), "error", err)
+				return
+			}
+		}
+		if closer, ok := enc.(expfmt.Closer); ok {
+			if err := closer.Close(); err != nil {
+				slog.Error("error finalizing backfill samples", "error", err)
+			}
+		}
+	})
+}
+
+// backfillFamilies groups the historic samples of all snapshots by metric, sorted by name.
+func (e *snapshotExporter) backfillFamilies() []*dto.MetricFamily {
+	familiesByName := map[string]*dto.MetricFamily{}
+	for _, s := range *e.snapshots.Load() {
+		for _, smpl := range s.samples {
+			family, ok := familiesByName[smpl.md.name]
+			if !ok {
+				family = &dto.MetricFamily{
+					Name: &smpl.md.name,
+					Help: &smpl.md.help,
+					Type: dto.MetricType_GAUGE.Enum(),
+				}
+				familiesByName[smpl.md.name] = family
+			}
+			metric := &dto.Metric{}
+			if err := smpl.metric.Write(metric); err != nil {
+				slog.Warn("skipping invalid sample", "metric", smpl.md.name, "error", err)
+				continue
+			}
+			family.Metric = append(family.Metric, metric)
+		}
+	}
+	families := make([]*dto.MetricFamily, 0, len(familiesByName))
+	for _, family := range familiesByName {
+		families = append(families, family)
+	}
+	slices.SortFunc(families, func(a, b *dto.MetricFamily) int {
+		return strings.Compare(a.GetName(), b.GetName())
+	})
+	return families
+}
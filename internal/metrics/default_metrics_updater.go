@@ -2,48 +2,730 @@ package metrics
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
+	"unicode"
 
 	"github.com/kodehat/netcupscp-exporter/internal/client"
 	"github.com/kodehat/netcupscp-exporter/internal/collector"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-func serverBaseLabels(server *client.Server) prometheus.Labels {
+func serverBaseLabels(account string, server *client.Server) prometheus.Labels {
 	return prometheus.Labels{
-		"servername":     *server.Name,
-		"servernickname": *server.Nickname,
+		"account":        account,
+		"servername":     deref(server.Name),
+		"servernickname": deref(server.Nickname),
 	}
 }
 
+// deref returns the value the pointer points to or the zero value for a nil pointer.
+func deref[T any](p *T) T {
+	var zero T
+	if p == nil {
+		return zero
+	}
+	return *p
+}
+
+// serverListLabels returns the base labels of a server taken from the server list.
+func serverListLabels(account string, server client.ServerListMinimal) prometheus.Labels {
+	labels := prometheus.Labels{"account": account}
+	if server.Name != nil {
+		labels["servername"] = *server.Name
+	}
+	if server.Nickname != nil {
+		labels["servernickname"] = *server.Nickname
+	}
+	return labels
+}
+
+// serverInfoLabels returns the hardware and configuration labels of a server. Values missing in the
+// response are left empty.
+func serverInfoLabels(server *client.Server) prometheus.Labels {
+	labels := prometheus.Labels{}
+	setString := func(label string, value *string) {
+		if value != nil {
+			labels[label] = *value
+		}
+	}
+	setBool := func(label string, value *bool) {
+		if value != nil {
+			labels[label] = strconv.FormatBool(*value)
+		}
+	}
+
+	setString("hostname", server.Hostname)
+	setBool("disabled", server.Disabled)
+	if server.Architecture != nil {
+		labels["architecture"] = string(*server.Architecture)
+	}
+	if server.Site != nil {
+		labels["site"] = server.Site.City
+	}
+	if server.Template != nil {
+		labels["template"] = server.Template.Name
+	}
+
+	info := server.ServerLiveInfo
+	if info == nil {
+		return labels
+	}
+	setString("machine_type", info.MachineType)
+	setString("keyboard_layout", info.KeyboardLayout)
+	setBool("uefi", info.Uefi)
+	setBool("nested_guest", info.NestedGuest)
+	setBool("cloudinit_attached", info.CloudinitAttached)
+	setBool("autostart", info.Autostart)
+	setBool("config_changed", info.ConfigChanged)
+	if _, ok := labels["template"]; !ok {
+		setString("template", info.Template)
+	}
+	if info.Bootorder != nil {
+		bootorder := make([]string, 0, len(*info.Bootorder))
+		for _, device := range *info.Bootorder {
+			bootorder = append(bootorder, string(device))
+		}
+		labels["bootorder"] = strings.Join(bootorder, ",")
+	}
+	return labels
+}
+
 type DefaultMetricsUpdater struct {
+	account   string
 	collector collector.ServerCollector
+	// metricsHours is the number of hours of metric series requested from the API.
+	metricsHours int32
+	// backfill enables keeping all samples of metric series for backfilling.
+	backfill bool
+	// maintenanceActive is set by the servers data group and pauses all other data groups.
+	maintenanceActive atomic.Bool
+	// lastServerInfos are the servers of the last successful refresh, served again during maintenance.
+	lastServerInfos []collector.ServerInfo
+	// failoverRoutes are the server names the failover IPs were routed to during the last refresh.
+	failoverRoutes map[failoverIpKey]string
+	// failoverRoutingChanges counts the routing changes of every failover IP seen so far.
+	failoverRoutingChanges map[failoverIpKey]int
 }
 
-var _ MetricsUpdater = DefaultMetricsUpdater{}
+type failoverIpKey struct {
+	ip, ipType string
+}
+
+var _ MetricsUpdater = &DefaultMetricsUpdater{}
 
-func NewDefaultMetricsUpdater(collector collector.ServerCollector) *DefaultMetricsUpdater {
+// NewDefaultMetricsUpdater creates a new DefaultMetricsUpdater that labels all metrics with the given account.
+// Metric series are requested for the last given hours. If backfill is set, all their samples are kept
+// with their original timestamps besides the latest value.
+func NewDefaultMetricsUpdater(account string, collector collector.ServerCollector, metricsHours int32, backfill bool) *DefaultMetricsUpdater {
 	return &DefaultMetricsUpdater{
-		collector: collector,
+		account:      account,
+		collector:    collector,
+		metricsHours: metricsHours,
+		backfill:     backfill,
+
+		failoverRoutes:         map[failoverIpKey]string{},
+		failoverRoutingChanges: map[failoverIpKey]int{},
+	}
+}
+
+// UpdateMetrics refreshes the metrics of the given data group. If the refresh fails, the last snapshot
+// of the data group is served further on. Only during maintenance the server metrics are rebuilt
+// from the last successful refresh, so that they can be marked as stale.
+func (mu *DefaultMetricsUpdater) UpdateMetrics(ctx context.Context, group DataGroup) (err error) {
+	start := time.Now()
+	defer func() { observeRefresh(mu.account, group, start, err) }()
+
+	if group != DataGroupServers && mu.maintenanceActive.Load() {
+		slog.Debug("skipping data group during maintenance", "account", mu.account, "group", group)
+		return collector.ErrMaintenanceOngoing
+	}
+
+	b := newSnapshotBuilder()
+	switch group {
+	case DataGroupServers:
+		err = mu.updateServerMetrics(ctx, b)
+	case DataGroupCpu:
+		err = mu.updateCpuMetrics(ctx, b)
+	case DataGroupDisk:
+		err = mu.updateDiskIoMetrics(ctx, b)
+	case DataGroupNetwork:
+		err = mu.updateNetworkMetrics(ctx, b)
+	case DataGroupSnapshots:
+		err = mu.updateSnapshotMetrics(ctx, b)
+	case DataGroupTasks:
+		err = mu.updateTaskMetrics(ctx, b)
+	case DataGroupFailoverIps:
+		err = mu.updateFailoverIpMetrics(ctx, b)
+	case DataGroupFirewalls:
+		err = mu.updateFirewallMetrics(ctx, b)
+	case DataGroupVlans:
+		err = mu.updateVlanMetrics(ctx, b)
+	case DataGroupRdns:
+		err = mu.updateRdnsMetrics(ctx, b)
+	case DataGroupGuestAgent:
+		err = mu.updateGuestAgentMetrics(ctx, b)
+	default:
+		return fmt.Errorf("unknown data group: %s", group)
+	}
+	if err != nil && !errors.Is(err, collector.ErrMaintenanceOngoing) {
+		return err
+	}
+	exporter.swap(mu.account+"/"+string(group), b.build())
+	return err
+}
+
+func (mu *DefaultMetricsUpdater) updateServerMetrics(ctx context.Context, b *snapshotBuilder) error {
+	serverData, err := mu.collector.CollectServerData(ctx)
+	if errors.Is(err, collector.ErrMaintenanceOngoing) && serverData != nil {
+		// Keep serving the servers of the last successful refresh, but mark them as stale.
+		mu.maintenanceActive.Store(true)
+		mu.updateMaintenanceMetrics(b, serverData)
+		b.set(dataStale, 1, prometheus.Labels{"account": mu.account})
+		mu.updateMetricsFromServerInfos(b, mu.lastServerInfos)
+		return err
+	} else if err != nil {
+		return err
+	}
+	mu.maintenanceActive.Store(false)
+	mu.updateMaintenanceMetrics(b, serverData)
+	b.set(dataStale, 0, prometheus.Labels{"account": mu.account})
+	mu.updateMetricsFromServerInfos(b, serverData.Servers)
+	mu.lastServerInfos = serverData.Servers
+	return nil
+}
+
+func (mu *DefaultMetricsUpdater) updateCpuMetrics(ctx context.Context, b *snapshotBuilder) error {
+	serverMetrics, err := mu.collector.CollectCpuMetrics(ctx, mu.metricsHours)
+	if err != nil {
+		return err
+	} else if err := allServersFailed(serverMetrics); err != nil {
+		return err
+	}
+	for _, sm := range serverMetrics {
+		// Servers whose metrics could not be fetched or decoded are logged by the collector and left out.
+		if sm.Err != nil {
+			continue
+		}
+		baseLabels := serverListLabels(mu.account, sm.Server)
+		for _, series := range sm.Data.Find(collector.SeriesCpuUtilization) {
+			mu.updateSeriesMetric(b, cpuUtilization, series, baseLabels)
+		}
+	}
+	return nil
+}
+
+func (mu *DefaultMetricsUpdater) updateDiskIoMetrics(ctx context.Context, b *snapshotBuilder) error {
+	serverMetrics, err := mu.collector.CollectDiskMetrics(ctx, mu.metricsHours)
+	if err != nil {
+		return err
+	} else if err := allServersFailed(serverMetrics); err != nil {
+		return err
+	}
+	seriesMetrics := map[string]*metricDesc{
+		collector.SeriesDiskReadBytes:  diskReadBytes,
+		collector.SeriesDiskWriteBytes: diskWriteBytes,
+		collector.SeriesDiskReadOps:    diskReadIops,
+		collector.SeriesDiskWriteOps:   diskWriteIops,
+	}
+	for _, sm := range serverMetrics {
+		if sm.Err != nil {
+			continue
+		}
+		baseLabels := serverListLabels(mu.account, sm.Server)
+		for name, md := range seriesMetrics {
+			for _, series := range sm.Data.Find(name) {
+				// Use the same disk label as the disk capacity metrics.
+				diskLabels := mergeLabels(baseLabels, prometheus.Labels{"name": series.Labels[collector.SeriesLabelDevice]})
+				mu.updateSeriesMetric(b, md, series, diskLabels)
+			}
+		}
+	}
+	return nil
+}
+
+func (mu *DefaultMetricsUpdater) updateNetworkMetrics(ctx context.Context, b *snapshotBuilder) error {
+	serverMetrics, err := mu.collector.CollectNetworkMetrics(ctx, mu.metricsHours)
+	if err != nil {
+		return err
+	} else if err := allServersFailed(serverMetrics); err != nil {
+		return err
+	}
+	seriesMetrics := map[string]*metricDesc{
+		collector.SeriesNetRxBits:    networkReceiveBits,
+		collector.SeriesNetTxBits:    networkTransmitBits,
+		collector.SeriesNetRxPackets: networkReceivePackets,
+		collector.SeriesNetTxPackets: networkTransmitPackets,
+	}
+	for _, sm := range serverMetrics {
+		if sm.Err != nil {
+			continue
+		}
+		baseLabels := serverListLabels(mu.account, sm.Server)
+		for name, md := range seriesMetrics {
+			for _, series := range sm.Data.Find(name) {
+				// Use the same interface label as the interface metrics.
+				ifaceLabels := mergeLabels(baseLabels, prometheus.Labels{"mac": series.Labels[collector.SeriesLabelMac]})
+				mu.updateSeriesMetric(b, md, series, ifaceLabels)
+			}
+		}
+	}
+	return nil
+}
+
+func (mu *DefaultMetricsUpdater) updateSnapshotMetrics(ctx context.Context, b *snapshotBuilder) error {
+	serverSnapshots, err := mu.collector.CollectSnapshots(ctx)
+	if err != nil {
+		return err
+	}
+	for _, ss := range serverSnapshots {
+		if ss.Err != nil {
+			continue
+		}
+		baseLabels := serverListLabels(mu.account, ss.Server)
+		for _, snapshot := range ss.Data {
+			if snapshot.Name == nil {
+				continue
+			}
+			snapshotLabels := mergeLabels(baseLabels, prometheus.Labels{"snapshot": *snapshot.Name})
+			if snapshot.CreationTime != nil {
+				b.set(snapshotCreated, float64(snapshot.CreationTime.Unix()), snapshotLabels)
+			}
+			if snapshot.Exported != nil {
+				exportedStatus := SNAPSHOT_NOT_EXPORTED
+				if *snapshot.Exported {
+					exportedStatus = SNAPSHOT_EXPORTED
+				}
+				b.set(snapshotExported, float64(exportedStatus), snapshotLabels)
+			}
+			if snapshot.ExportedSizeInKiB != nil {
+				b.set(snapshotExportedSize, float64(*snapshot.ExportedSizeInKiB)*1024, snapshotLabels)
+			}
+		}
+	}
+	return nil
+}
+
+func (mu *DefaultMetricsUpdater) updateTaskMetrics(ctx context.Context, b *snapshotBuilder) error {
+	serverTasks, err := mu.collector.CollectTasks(ctx)
+	if err != nil {
+		return err
+	}
+	for _, st := range serverTasks {
+		if st.Err != nil {
+			continue
+		}
+		baseLabels := serverListLabels(mu.account, st.Server)
+
+		type taskKey struct{ name, state string }
+		counts := map[taskKey]int{}
+		rollbacks := map[string]int{}
+		lastFinished := map[string]client.TaskInfoMinimal{}
+		for _, task := range st.Data {
+			if task.Name == nil || task.State == nil {
+				continue
+			}
+			name := *task.Name
+			counts[taskKey{name, string(*task.State)}]++
+			if task.OnRollback != nil && *task.OnRollback {
+				rollbacks[name]++
+			}
+
+			switch *task.State {
+			case client.TaskStateFINISHED:
+				// Keep the task that finished last for every name.
+				if task.StartedAt == nil || task.FinishedAt == nil {
+					continue
+				}
+				if last, ok := lastFinished[name]; !ok || task.FinishedAt.After(*last.FinishedAt) {
+					lastFinished[name] = task
+				}
+			case client.TaskStateRUNNING:
+				if task.Uuid == nil || task.TaskProgress == nil || task.TaskProgress.ProgressInPercent == nil {
+					continue
+				}
+				b.set(taskProgress, float64(*task.TaskProgress.ProgressInPercent), mergeLabels(baseLabels, prometheus.Labels{"task": name, "uuid": *task.Uuid}))
+			}
+		}
+
+		for key, count := range counts {
+			b.set(tasks, float64(count), mergeLabels(baseLabels, prometheus.Labels{"task": key.name, "state": key.state}))
+		}
+		for name, count := range rollbacks {
+			b.set(tasksOnRollback, float64(count), mergeLabels(baseLabels, prometheus.Labels{"task": name}))
+		}
+		for name, task := range lastFinished {
+			b.set(taskLastDuration, task.FinishedAt.Sub(*task.StartedAt).Seconds(), mergeLabels(baseLabels, prometheus.Labels{"task": name}))
+		}
+	}
+	return nil
+}
+
+func (mu *DefaultMetricsUpdater) updateFailoverIpMetrics(ctx context.Context, b *snapshotBuilder) error {
+	failoverIps, err := mu.collector.CollectFailoverIps(ctx)
+	if err != nil {
+		return err
+	}
+	for _, ip := range failoverIps.V4 {
+		if ip.Ip == nil {
+			continue
+		}
+		mu.updateFailoverIpMetric(b, failoverIpKey{*ip.Ip, "ipv4"}, ip.CidrSuffix, ip.Site, ip.Server)
+	}
+	for _, ip := range failoverIps.V6 {
+		if ip.NetworkPrefix == nil {
+			continue
+		}
+		mu.updateFailoverIpMetric(b, failoverIpKey{*ip.NetworkPrefix, "ipv6"}, ip.NetworkPrefixLength, ip.Site, ip.Server)
+	}
+	return nil
+}
+
+// updateFailoverIpMetric exports the failover IP and counts a routing change if it is routed
+// to another server than during the last refresh.
+func (mu *DefaultMetricsUpdater) updateFailoverIpMetric(b *snapshotBuilder, key failoverIpKey, cidr *int32, site *client.Site, server *client.ServerMinimal) {
+	labels := prometheus.Labels{"account": mu.account, "ip": key.ip, "type": key.ipType}
+	infoLabels := mergeLabels(labels, prometheus.Labels{"servername": ""})
+	if cidr != nil {
+		infoLabels["cidr"] = fmt.Sprintf("%d", *cidr)
+	}
+	if site != nil {
+		infoLabels["site"] = site.City
+	}
+	if server != nil && server.Name != nil {
+		infoLabels["servername"] = *server.Name
+	}
+	b.set(failoverIpInfo, 1, infoLabels)
+
+	if lastServerName, ok := mu.failoverRoutes[key]; ok && lastServerName != infoLabels["servername"] {
+		mu.failoverRoutingChanges[key]++
+	}
+	mu.failoverRoutes[key] = infoLabels["servername"]
+	b.set(failoverIpRoutingChanges, float64(mu.failoverRoutingChanges[key]), labels)
+}
+
+func (mu *DefaultMetricsUpdater) updateFirewallMetrics(ctx context.Context, b *snapshotBuilder) error {
+	serverFirewalls, err := mu.collector.CollectFirewalls(ctx)
+	if err != nil {
+		return err
 	}
+	for _, sf := range serverFirewalls {
+		if sf.Err != nil {
+			continue
+		}
+		baseLabels := serverListLabels(mu.account, sf.Server)
+		for _, iface := range sf.Data {
+			ifaceLabels := mergeLabels(baseLabels, prometheus.Labels{"mac": iface.Mac})
+			firewall := iface.Firewall
+
+			if firewall.Active != nil {
+				activeStatus := FIREWALL_INACTIVE
+				if *firewall.Active {
+					activeStatus = FIREWALL_ACTIVE
+				}
+				b.set(firewallActive, float64(activeStatus), ifaceLabels)
+			}
+			if firewall.Consistent != nil {
+				consistentStatus := FIREWALL_INCONSISTENT
+				if *firewall.Consistent {
+					consistentStatus = FIREWALL_CONSISTENT
+				}
+				b.set(firewallConsistent, float64(consistentStatus), ifaceLabels)
+			}
+
+			// Update assigned policies.
+			if firewall.UserPolicies != nil {
+				mu.updateFirewallPolicyMetrics(b, *firewall.UserPolicies, "user", ifaceLabels)
+			}
+			if firewall.CopiedPolicies != nil {
+				mu.updateFirewallPolicyMetrics(b, *firewall.CopiedPolicies, "copied", ifaceLabels)
+			}
+
+			// Update implicit rules.
+			if firewall.IngressImplicitRule != nil {
+				b.set(firewallImplicitRule, 1, mergeLabels(ifaceLabels, prometheus.Labels{"direction": string(client.INGRESS), "rule": string(*firewall.IngressImplicitRule)}))
+			}
+			if firewall.EgressImplicitRule != nil {
+				b.set(firewallImplicitRule, 1, mergeLabels(ifaceLabels, prometheus.Labels{"direction": string(client.EGRESS), "rule": string(*firewall.EgressImplicitRule)}))
+			}
+		}
+	}
+	return nil
 }
 
-func (mu DefaultMetricsUpdater) UpdateMetrics(context context.Context) error {
-	serverInfos, err := mu.collector.CollectServerData(context)
+func (mu *DefaultMetricsUpdater) updateFirewallPolicyMetrics(b *snapshotBuilder, policies []client.FirewallPolicy, policyType string, ifaceLabels prometheus.Labels) {
+	for _, policy := range policies {
+		if policy.Name == nil {
+			continue
+		}
+		policyLabels := mergeLabels(ifaceLabels, prometheus.Labels{"policy": *policy.Name, "type": policyType})
+		b.set(firewallPolicyInfo, 1, policyLabels)
+
+		// A rule without number of effective rules counts as a single rule.
+		effectiveRules := 0
+		if policy.Rules != nil {
+			for _, rule := range *policy.Rules {
+				if rule.NumberOfEffectiveRules == nil {
+					effectiveRules++
+					continue
+				}
+				effectiveRules += int(*rule.NumberOfEffectiveRules)
+			}
+		}
+		b.set(firewallPolicyRules, float64(effectiveRules), policyLabels)
+	}
+}
+
+func (mu *DefaultMetricsUpdater) updateVlanMetrics(ctx context.Context, b *snapshotBuilder) error {
+	vlans, err := mu.collector.CollectVlans(ctx)
 	if err != nil {
 		return err
 	}
-	reset()
-	mu.updateMetricsFromServerInfos(serverInfos)
+	for _, vlan := range vlans {
+		if vlan.VlanId == nil {
+			continue
+		}
+		vlanLabels := prometheus.Labels{"account": mu.account, "vlan_id": fmt.Sprintf("%d", *vlan.VlanId)}
+		if vlan.Name != nil {
+			vlanLabels["name"] = *vlan.Name
+		}
+		if vlan.Site != nil {
+			vlanLabels["site"] = vlan.Site.City
+		}
+		if vlan.BandwidthClass != nil {
+			vlanLabels["bandwidth_class"] = vlan.BandwidthClass.Name
+		}
+		b.set(vlanInfo, 1, vlanLabels)
+	}
 	return nil
 }
 
-func (mu DefaultMetricsUpdater) updateInterfaceMetrics(server *client.Server) {
-	baseLabels := serverBaseLabels(server)
+func (mu *DefaultMetricsUpdater) updateRdnsMetrics(ctx context.Context, b *snapshotBuilder) error {
+	serverRdns, err := mu.collector.CollectRdns(ctx)
+	if err != nil {
+		return err
+	}
+	for _, sr := range serverRdns {
+		if sr.Err != nil {
+			continue
+		}
+		baseLabels := serverListLabels(mu.account, sr.Server)
+		for _, record := range sr.Data.Records {
+			// Entries that could not be fetched are logged by the collector and left out.
+			if record.Err != nil {
+				continue
+			}
+			ipLabels := mergeLabels(baseLabels, prometheus.Labels{"ip": record.Ip, "type": record.Type})
+			b.set(rdnsInfo, 1, mergeLabels(ipLabels, prometheus.Labels{"ptr": record.Ptr}))
 
-	// Update interface specific metrics.
-	for _, iface := range *server.ServerLiveInfo.Interfaces {
+			matchStatus := RDNS_MISMATCH
+			if ptrMatchesHostname(record.Ptr, sr.Data.Hostname) {
+				matchStatus = RDNS_MATCH
+			}
+			b.set(rdnsMatchesHostname, float64(matchStatus), ipLabels)
+		}
+	}
+	return nil
+}
+
+func (mu *DefaultMetricsUpdater) updateGuestAgentMetrics(ctx context.Context, b *snapshotBuilder) error {
+	serverGuestAgents, err := mu.collector.CollectGuestAgents(ctx)
+	if err != nil {
+		return err
+	}
+	for _, sg := range serverGuestAgents {
+		if sg.Err != nil {
+			continue
+		}
+		baseLabels := serverListLabels(mu.account, sg.Server)
+		info := sg.Data
+
+		availableStatus := GUEST_AGENT_UNAVAILABLE
+		if info.Available {
+			availableStatus = GUEST_AGENT_AVAILABLE
+		}
+		b.set(guestAgentAvailable, float64(availableStatus), baseLabels)
+
+		// Update operating system and hostname info.
+		if info.OsInfo != nil {
+			b.set(guestOsInfo, 1, mergeLabels(baseLabels, prometheus.Labels{
+				"id":             info.OsInfo.Id,
+				"name":           info.OsInfo.PrettyName,
+				"version":        info.OsInfo.VersionId,
+				"kernel_release": info.OsInfo.KernelRelease,
+				"machine":        info.OsInfo.Machine,
+			}))
+		}
+		if info.HostName != "" {
+			b.set(guestHostnameInfo, 1, mergeLabels(baseLabels, prometheus.Labels{"hostname": info.HostName}))
+		}
+
+		// Update filesystem usage.
+		for _, fs := range info.Filesystems {
+			fsLabels := mergeLabels(baseLabels, prometheus.Labels{"device": fs.Name, "mountpoint": fs.Mountpoint, "fstype": fs.Type})
+			if fs.TotalBytes != nil {
+				b.set(guestFilesystemSize, float64(*fs.TotalBytes), fsLabels)
+			}
+			if fs.UsedBytes != nil {
+				b.set(guestFilesystemUsed, float64(*fs.UsedBytes), fsLabels)
+			}
+		}
+
+		// Update ip addresses configured inside the guest.
+		for _, iface := range info.NetworkInterfaces {
+			for _, ip := range iface.IpAddresses {
+				b.set(guestIpInfo, 1, mergeLabels(baseLabels, prometheus.Labels{
+					"interface": iface.Name,
+					"mac":       iface.HardwareAddress,
+					"ip":        ip.Address,
+					"prefix":    fmt.Sprintf("%d", ip.Prefix),
+					"type":      ip.Type,
+				}))
+			}
+		}
+	}
+	return nil
+}
+
+// setStateSet sets the stateset to 1 for the current state and to 0 for all other states.
+func setStateSet[S ~string](b *snapshotBuilder, md *metricDesc, states []S, current S, labels prometheus.Labels) {
+	stateLabel := md.name
+	for _, state := range states {
+		value := 0.0
+		if state == current {
+			value = 1
+		}
+		b.set(md, value, mergeLabels(labels, prometheus.Labels{stateLabel: string(state)}))
+	}
+}
+
+// templateOsKeywords map words of template names to the os optimization their operating system needs.
+var templateOsKeywords = map[string]client.OsOptimization{
+	"windows":     client.WINDOWS,
+	"bsd":         client.BSD,
+	"freebsd":     client.BSD,
+	"openbsd":     client.BSD,
+	"netbsd":      client.BSD,
+	"debian":      client.LINUX,
+	"ubuntu":      client.LINUX,
+	"centos":      client.LINUX,
+	"rocky":       client.LINUX,
+	"rockylinux":  client.LINUX,
+	"alma":        client.LINUX,
+	"almalinux":   client.LINUX,
+	"fedora":      client.LINUX,
+	"suse":        client.LINUX,
+	"opensuse":    client.LINUX,
+	"archlinux":   client.LINUX,
+	"alpine":      client.LINUX,
+	"rhel":        client.LINUX,
+	"oraclelinux": client.LINUX,
+	"linux":       client.LINUX,
+}
+
+// expectedOsOptimization guesses the os optimization from the name of the server's template. The name
+// is split into words, so that e.g. "Archive" is not taken for Arch Linux. It reports false if no word
+// hints at a known operating system.
+func expectedOsOptimization(template string) (client.OsOptimization, bool) {
+	words := strings.FieldsFunc(strings.ToLower(template), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for i, word := range words {
+		if word == "arch" && i+1 < len(words) && words[i+1] == "linux" {
+			return client.LINUX, true
+		}
+		if osOptimization, ok := templateOsKeywords[word]; ok {
+			return osOptimization, true
+		}
+	}
+	return "", false
+}
+
+// osOptimizationMatches tells if the actual os optimization fits the expected one. Older Linux
+// distributions need the legacy optimization, so it is accepted for Linux templates, too.
+func osOptimizationMatches(expected, actual client.OsOptimization) bool {
+	return actual == expected || (expected == client.LINUX && actual == client.LINUXLEGACY)
+}
+
+// ptrMatchesHostname compares a reverse DNS entry with a hostname ignoring case and trailing dots.
+// An empty reverse DNS entry never matches.
+func ptrMatchesHostname(ptr, hostname string) bool {
+	ptr = strings.TrimSuffix(ptr, ".")
+	hostname = strings.TrimSuffix(hostname, ".")
+	return ptr != "" && strings.EqualFold(ptr, hostname)
+}
+
+// allServersFailed returns the joined errors of all servers if none of them could be fetched, so that the
+// refresh is reported as failed instead of silently exporting nothing. It returns nil without any servers.
+func allServersFailed[T any](results []collector.ServerResult[T]) error {
+	errs := make([]error, 0, len(results))
+	for _, result := range results {
+		if result.Err == nil {
+			return nil
+		}
+		errs = append(errs, result.Err)
+	}
+	return errors.Join(errs...)
+}
+
+// updateSeriesMetric sets the metric to the latest value of the series and, if backfilling is
+// enabled, adds all values of the series as samples.
+func (mu *DefaultMetricsUpdater) updateSeriesMetric(b *snapshotBuilder, md *metricDesc, series collector.MetricSeries, labels prometheus.Labels) {
+	if latest, ok := series.Latest(); ok {
+		b.set(md, latest.Value, labels)
+	}
+	if !mu.backfill {
+		return
+	}
+	// The order of the API is not documented, but backfilling requires increasing timestamps per series.
+	points := slices.Clone(series.Values)
+	slices.SortStableFunc(points, func(a, b collector.DataPoint) int {
+		return a.Time.Compare(b.Time)
+	})
+	for i, point := range points {
+		// Keep only the last of several points sharing a timestamp.
+		if i+1 < len(points) && points[i+1].Time.Equal(point.Time) {
+			continue
+		}
+		b.addSample(md, point.Value, point.Time, labels)
+	}
+}
+
+func (mu *DefaultMetricsUpdater) updateMaintenanceMetrics(b *snapshotBuilder, serverData *collector.ServerData) {
+	accountLabels := prometheus.Labels{"account": mu.account}
+
+	maintenanceStatus := MAINTENANCE_INACTIVE
+	if serverData.MaintenanceActive {
+		maintenanceStatus = MAINTENANCE_ACTIVE
+	}
+	b.set(maintenanceActive, float64(maintenanceStatus), accountLabels)
+
+	// Export the maintenance window only if one is announced.
+	if serverData.Maintenance == nil {
+		return
+	}
+	if serverData.Maintenance.StartAt != nil {
+		b.set(maintenanceStart, float64(serverData.Maintenance.StartAt.Unix()), accountLabels)
+	}
+	if serverData.Maintenance.FinishAt != nil {
+		b.set(maintenanceEnd, float64(serverData.Maintenance.FinishAt.Unix()), accountLabels)
+	}
+}
+
+func (mu *DefaultMetricsUpdater) updateInterfaceMetrics(b *snapshotBuilder, server *client.Server) {
+	baseLabels := serverBaseLabels(mu.account, server)
+
+	// Update interface specific metrics. Interfaces without mac address cannot be told apart and are left out.
+	for _, iface := range deref(server.ServerLiveInfo.Interfaces) {
+		if iface.Mac == nil {
+			continue
+		}
 		trafficLabels := prometheus.Labels{
 			"month": fmt.Sprintf("%02d", time.Now().Month()),
 			"year":  fmt.Sprintf("%d", time.Now().Year()),
@@ -51,88 +733,205 @@ func (mu DefaultMetricsUpdater) updateInterfaceMetrics(server *client.Server) {
 		}
 
 		// Update interface traffic metrics.
-		monthlyTrafficIn.With(mergeLabels(baseLabels, trafficLabels)).Set(float64(*iface.RxMonthlyInMiB) * 1024 * 1024)
-		monthlyTrafficOut.With(mergeLabels(baseLabels, trafficLabels)).Set(float64(*iface.TxMonthlyInMiB) * 1024 * 1024)
-		monthlyTrafficTotal.With(mergeLabels(baseLabels, trafficLabels)).Set(float64(*iface.TxMonthlyInMiB+*iface.RxMonthlyInMiB) * 1024 * 1024)
+		if iface.RxMonthlyInMiB != nil && iface.TxMonthlyInMiB != nil {
+			b.set(monthlyTrafficIn, float64(*iface.RxMonthlyInMiB)*1024*1024, mergeLabels(baseLabels, trafficLabels))
+			b.set(monthlyTrafficOut, float64(*iface.TxMonthlyInMiB)*1024*1024, mergeLabels(baseLabels, trafficLabels))
+			b.set(monthlyTrafficTotal, float64(*iface.TxMonthlyInMiB+*iface.RxMonthlyInMiB)*1024*1024, mergeLabels(baseLabels, trafficLabels))
+		}
 
 		// Update interface throttled status.
-		ifaceThrottledSatus := INTERFACE_NOT_THROTTLED
-		if *iface.TrafficThrottled {
-			ifaceThrottledSatus = INTERFACE_THROTTLED
+		if iface.TrafficThrottled != nil {
+			ifaceThrottledSatus := INTERFACE_NOT_THROTTLED
+			if *iface.TrafficThrottled {
+				ifaceThrottledSatus = INTERFACE_THROTTLED
+			}
+			b.set(ifaceThrottled, float64(ifaceThrottledSatus), mergeLabels(baseLabels, prometheus.Labels{"mac": *iface.Mac, "status": ifaceThrottledSatus.String()}))
+		}
+
+		// Update interface VLAN attachment.
+		if iface.VlanInterface != nil && *iface.VlanInterface && iface.VlanId != nil {
+			b.set(ifaceVlanInfo, 1, mergeLabels(baseLabels, prometheus.Labels{"mac": *iface.Mac, "vlan_id": fmt.Sprintf("%d", *iface.VlanId)}))
 		}
-		ifaceThrottled.With(mergeLabels(baseLabels, prometheus.Labels{"mac": *iface.Mac, "status": ifaceThrottledSatus.String()})).Set(float64(ifaceThrottledSatus))
 
 		// Update interface IPv4 info.
-		for _, ip := range *iface.Ipv4Addresses {
+		for _, ip := range deref(iface.Ipv4Addresses) {
 			ipv4Labels := mergeLabels(baseLabels, prometheus.Labels{"mac": *iface.Mac, "ip": ip, "type": "ipv4"})
-			serverIpInfo.With(ipv4Labels).Set(1)
+			b.set(serverIpInfo, 1, ipv4Labels)
 		}
 		// Update interface IPv6 info.
-		for _, ip := range *iface.Ipv6NetworkPrefixes {
+		for _, ip := range deref(iface.Ipv6NetworkPrefixes) {
 			ipv6Labels := mergeLabels(baseLabels, prometheus.Labels{"mac": *iface.Mac, "ip": ip, "type": "ipv6"})
-			serverIpInfo.With(ipv6Labels).Set(1)
+			b.set(serverIpInfo, 1, ipv6Labels)
 		}
 	}
 }
 
-func (mu DefaultMetricsUpdater) updateDiskMetrics(server *client.Server) {
-	baseLabels := serverBaseLabels(server)
+// updateCpuAndMemoryMetrics sets the current and maximum vCPUs and memory as well as the CPU topology.
+// Current values fall back to the maximum if the server does not report them.
+func (mu *DefaultMetricsUpdater) updateCpuAndMemoryMetrics(b *snapshotBuilder, server *client.Server, baseLabels prometheus.Labels) {
+	info := server.ServerLiveInfo
+
+	maxCpuCount := info.CpuMaxCount
+	if maxCpuCount == nil {
+		maxCpuCount = server.MaxCpuCount
+	}
+	cpuCount := info.CpuCount
+	if cpuCount == nil {
+		cpuCount = maxCpuCount
+	}
+	if cpuCount != nil {
+		b.set(cpuCores, float64(*cpuCount), baseLabels)
+	}
+	if maxCpuCount != nil {
+		b.set(cpuCoresMax, float64(*maxCpuCount), baseLabels)
+	}
+	if info.Sockets != nil {
+		b.set(cpuSockets, float64(*info.Sockets), baseLabels)
+	}
+	if info.CoresPerSocket != nil {
+		b.set(cpuCoresPerSocket, float64(*info.CoresPerSocket), baseLabels)
+	}
+
+	memoryInMiB := info.CurrentServerMemoryInMiB
+	if memoryInMiB == nil {
+		memoryInMiB = info.MaxServerMemoryInMiB
+	}
+	if memoryInMiB != nil {
+		b.set(memory, float64(*memoryInMiB)*1024*1024, baseLabels)
+	}
+	if info.MaxServerMemoryInMiB != nil {
+		b.set(memoryMax, float64(*info.MaxServerMemoryInMiB)*1024*1024, baseLabels)
+	}
+}
+
+func (mu *DefaultMetricsUpdater) updateDiskMetrics(b *snapshotBuilder, server *client.Server) {
+	baseLabels := serverBaseLabels(mu.account, server)
 
 	// Update disk optimization status.
-	diskOptStatus := DISK_OPTIMIZATION_YES
-	if *server.ServerLiveInfo.RequiredStorageOptimization == client.NO {
-		diskOptStatus = DISK_OPTIMIZATION_NO
+	if storageOpt := server.ServerLiveInfo.RequiredStorageOptimization; storageOpt != nil {
+		diskOptStatus := DISK_OPTIMIZATION_YES
+		if *storageOpt == client.NO {
+			diskOptStatus = DISK_OPTIMIZATION_NO
+		}
+		b.set(diskOptimization, float64(diskOptStatus), mergeLabels(baseLabels, prometheus.Labels{"status": diskOptStatus.String()}))
+		setStateSet(b, storageOptimization, storageOptimizations, *storageOpt, baseLabels)
 	}
-	diskOptimization.With(mergeLabels(baseLabels, prometheus.Labels{"status": diskOptStatus.String()})).Set(float64(diskOptStatus))
 
 	// Update disk capacity and disk usage metrics.
-	for _, disk := range *server.ServerLiveInfo.Disks {
+	for _, disk := range deref(server.ServerLiveInfo.Disks) {
 		diskLabels := mergeLabels(baseLabels, prometheus.Labels{
-			"driver": *disk.Driver,
-			"name":   *disk.Dev,
+			"driver": deref(disk.Driver),
+			"name":   deref(disk.Dev),
 		})
-		diskCapacity.With(diskLabels).Set(float64(*disk.CapacityInMiB) * 1024 * 1024)
-		diskUsed.With(diskLabels).Set(float64(*disk.AllocationInMiB) * 1024 * 1024)
+		if disk.CapacityInMiB != nil {
+			b.set(diskCapacity, float64(*disk.CapacityInMiB)*1024*1024, diskLabels)
+		}
+		if disk.AllocationInMiB != nil {
+			b.set(diskUsed, float64(*disk.AllocationInMiB)*1024*1024, diskLabels)
+		}
 	}
 }
 
-func (mu DefaultMetricsUpdater) updateMetricsFromServerInfos(serverInfos []collector.ServerInfo) {
+func (mu *DefaultMetricsUpdater) updateMetricsFromServerInfos(b *snapshotBuilder, serverInfos []collector.ServerInfo) {
 	for _, serverInfo := range serverInfos {
+		scrapeLabels := prometheus.Labels{"account": mu.account, "servername": serverInfo.ServerName}
+		// Report failed servers only by their scrape status and export all others as usual.
+		if serverInfo.Err != nil {
+			b.set(serverScrapeSuccess, 0, scrapeLabels)
+			continue
+		}
+		// Servers lacking their name or live info cannot be labeled or described, so they are reported as failed, too.
 		server := serverInfo.Server
-		baseLabels := serverBaseLabels(server)
+		if server == nil || server.Name == nil || server.ServerLiveInfo == nil {
+			slog.Warn("server without name or live info", "account", mu.account, "serverId", serverInfo.ServerId)
+			b.set(serverScrapeSuccess, 0, scrapeLabels)
+			continue
+		}
+		b.set(serverScrapeSuccess, 1, scrapeLabels)
+
+		info := server.ServerLiveInfo
+		baseLabels := serverBaseLabels(mu.account, server)
 
 		// Update CPU and memory.
-		cpuCores.With(baseLabels).Set(float64(*server.MaxCpuCount))
-		memory.With(baseLabels).Set(float64(*server.ServerLiveInfo.MaxServerMemoryInMiB) * 1024 * 1024)
+		mu.updateCpuAndMemoryMetrics(b, server, baseLabels)
+
+		// Update hardware and configuration info.
+		b.set(serverConfigInfo, 1, mergeLabels(baseLabels, serverInfoLabels(server)))
+		if info.ConfigChanged != nil {
+			configChangedStatus := CONFIG_UNCHANGED
+			if *info.ConfigChanged {
+				configChangedStatus = CONFIG_CHANGED
+			}
+			b.set(serverConfigChanged, float64(configChangedStatus), baseLabels)
+		}
+
+		// Update snapshot inventory.
+		if server.SnapshotCount != nil {
+			b.set(snapshotCount, float64(*server.SnapshotCount), baseLabels)
+		}
+		if server.SnapshotAllowed != nil {
+			snapshotAllowedStatus := SNAPSHOT_NOT_ALLOWED
+			if *server.SnapshotAllowed {
+				snapshotAllowedStatus = SNAPSHOT_ALLOWED
+			}
+			b.set(snapshotAllowed, float64(snapshotAllowedStatus), baseLabels)
+		}
 
 		// Update other server metrics (like uptime).
-		serverStartTimeSeconds.With(baseLabels).Set(float64(*server.ServerLiveInfo.UptimeInSeconds))
+		if info.UptimeInSeconds != nil {
+			b.set(serverStartTimeSeconds, float64(*info.UptimeInSeconds), baseLabels)
+		}
 
 		// Update server status.
-		onlineStatus := SERVER_STATUS_ONLINE
-		if *server.ServerLiveInfo.State == client.SHUTOFF {
-			onlineStatus = SERVER_STATUS_OFFLINE
+		if info.State != nil {
+			onlineStatus := SERVER_STATUS_ONLINE
+			if *info.State == client.SHUTOFF {
+				onlineStatus = SERVER_STATUS_OFFLINE
+			}
+			b.set(serverStatus, float64(onlineStatus), mergeLabels(baseLabels, prometheus.Labels{"status": onlineStatus.String()}))
+			setStateSet(b, serverState, serverStates, *info.State, baseLabels)
+		}
+
+		// Update os optimization and compare it with the template.
+		if info.OsOptimization != nil {
+			setStateSet(b, osOptimization, osOptimizations, *info.OsOptimization, baseLabels)
+			var template string
+			if server.Template != nil {
+				template = server.Template.Name
+			} else if info.Template != nil {
+				template = *info.Template
+			}
+			if expected, ok := expectedOsOptimization(template); ok {
+				mismatchStatus := OS_OPTIMIZATION_MATCH
+				if !osOptimizationMatches(expected, *info.OsOptimization) {
+					mismatchStatus = OS_OPTIMIZATION_MISMATCH
+				}
+				b.set(osOptimizationMismatch, float64(mismatchStatus), mergeLabels(baseLabels, prometheus.Labels{"template": template, "expected": string(expected)}))
+			}
 		}
-		serverStatus.With(mergeLabels(baseLabels, prometheus.Labels{"status": onlineStatus.String()})).Set(float64(onlineStatus))
 
 		// Update rescue system status.
-		rescueStatus := RESCUE_SYSTEM_INACTIVE
-		if *server.RescueSystemActive {
-			rescueStatus = RESCUE_SYSTEM_ACTIVE
+		if server.RescueSystemActive != nil {
+			rescueStatus := RESCUE_SYSTEM_INACTIVE
+			if *server.RescueSystemActive {
+				rescueStatus = RESCUE_SYSTEM_ACTIVE
+			}
+			b.set(rescueActive, float64(rescueStatus), mergeLabels(baseLabels, prometheus.Labels{"status": rescueStatus.String()}))
 		}
-		rescueActive.With(mergeLabels(baseLabels, prometheus.Labels{"status": rescueStatus.String()})).Set(float64(rescueStatus))
 
 		// Update reboot recommendation status.
-		rebootRecStatus := REBOOT_NOT_RECOMMENDED
-		if !*server.ServerLiveInfo.LatestQemu {
-			rebootRecStatus = REBOOT_RECOMMENDED
+		if info.LatestQemu != nil {
+			rebootRecStatus := REBOOT_NOT_RECOMMENDED
+			if !*info.LatestQemu {
+				rebootRecStatus = REBOOT_RECOMMENDED
+			}
+			b.set(rebootRecommended, float64(rebootRecStatus), mergeLabels(baseLabels, prometheus.Labels{"status": rebootRecStatus.String()}))
 		}
-		rebootRecommended.With(mergeLabels(baseLabels, prometheus.Labels{"status": rebootRecStatus.String()})).Set(float64(rebootRecStatus))
 
 		// Update interface metrics.
-		mu.updateInterfaceMetrics(server)
+		mu.updateInterfaceMetrics(b, server)
 
 		// Update disk metrics.
-		mu.updateDiskMetrics(server)
+		mu.updateDiskMetrics(b, server)
 	}
 }
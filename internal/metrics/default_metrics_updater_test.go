@@ -0,0 +1,202 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kodehat/netcupscp-exporter/internal/client"
+	"github.com/kodehat/netcupscp-exporter/internal/collector"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPtrMatchesHostname(t *testing.T) {
+	tests := []struct {
+		name          string
+		ptr, hostname string
+		want          bool
+	}{
+		{"equal", "mail.example.com", "mail.example.com", true},
+		{"trailing dot", "mail.example.com.", "mail.example.com", true},
+		{"different case", "Mail.Example.com", "mail.example.COM", true},
+		{"different host", "v2202501.example.net", "mail.example.com", false},
+		{"empty ptr", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ptrMatchesHostname(tt.ptr, tt.hostname); got != tt.want {
+				t.Errorf("ptrMatchesHostname(%q, %q) = %v; want %v", tt.ptr, tt.hostname, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOsOptimizationMatchesTemplate(t *testing.T) {
+	tests := []struct {
+		name      string
+		template  string
+		actual    client.OsOptimization
+		wantKnown bool
+		wantMatch bool
+	}{
+		{"debian linux", "Debian 12 (Bookworm)", client.LINUX, true, true},
+		{"legacy linux", "CentOS 7", client.LINUXLEGACY, true, true},
+		{"linux as windows", "Ubuntu 24.04", client.WINDOWS, true, false},
+		{"windows", "Windows Server 2022", client.WINDOWS, true, true},
+		{"windows as linux", "Windows Server 2022", client.LINUX, true, false},
+		{"bsd", "FreeBSD 14", client.BSD, true, true},
+		{"unknown optimization", "Debian 12", client.UNKNOWN, true, false},
+		{"unknown template", "Custom image", client.LINUX, false, false},
+		{"arch linux", "Arch Linux", client.LINUX, true, true},
+		{"word containing arch", "Search appliance", client.WINDOWS, false, false},
+		{"windows archive", "Windows Archive Server", client.WINDOWS, true, true},
+		{"version suffix", "Ubuntu24.04", client.LINUX, true, true},
+		{"no template", "", client.LINUX, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expected, known := expectedOsOptimization(tt.template)
+			if known != tt.wantKnown {
+				t.Fatalf("expectedOsOptimization(%q) known = %v; want %v", tt.template, known, tt.wantKnown)
+			}
+			if !known {
+				return
+			}
+			if got := osOptimizationMatches(expected, tt.actual); got != tt.wantMatch {
+				t.Errorf("osOptimizationMatches(%q, %q) = %v; want %v", expected, tt.actual, got, tt.wantMatch)
+			}
+		})
+	}
+}
+
+func TestServerInfoLabels(t *testing.T) {
+	architecture := client.Architecture("AMD64")
+	hostname, template := "v2202501.example.net", "Debian 12"
+	uefi, autostart := true, false
+	server := &client.Server{
+		Architecture: &architecture,
+		Hostname:     &hostname,
+		Site:         &client.Site{City: "Nuremberg"},
+		ServerLiveInfo: &client.ServerInfo{
+			Uefi:      &uefi,
+			Autostart: &autostart,
+			Bootorder: &[]client.Bootorder{"HDD", "CDROM"},
+			Template:  &template,
+		},
+	}
+
+	labels := serverInfoLabels(server)
+	want := map[string]string{
+		"architecture": "AMD64",
+		"hostname":     hostname,
+		"site":         "Nuremberg",
+		"uefi":         "true",
+		"autostart":    "false",
+		"bootorder":    "HDD,CDROM",
+		"template":     "Debian 12",
+	}
+	for label, value := range want {
+		if labels[label] != value {
+			t.Errorf("label %q = %q; want %q", label, labels[label], value)
+		}
+	}
+	if _, ok := labels["nested_guest"]; ok {
+		t.Errorf("label %q set although missing in the response", "nested_guest")
+	}
+}
+
+func TestUpdateMetricsFromServerInfosWithMissingFields(t *testing.T) {
+	name := "v1"
+	mu := NewDefaultMetricsUpdater("a", nil, 1, false)
+	b := newSnapshotBuilder()
+	mu.updateMetricsFromServerInfos(b, []collector.ServerInfo{
+		{ServerId: 1, ServerName: "v1", Server: &client.Server{Name: &name}},
+		{ServerId: 2, ServerName: "v2", Server: &client.Server{Name: &name, ServerLiveInfo: &client.ServerInfo{
+			Interfaces: &[]client.ServerInterface{{}},
+			Disks:      &[]client.ServerDisk{{}},
+		}}},
+	})
+
+	registry := prometheus.NewRegistry()
+	e := newSnapshotExporter()
+	e.swap("a/servers", b.build())
+	registry.MustRegister(e)
+	want := `
+# HELP ncscp_server_scrape_success Server data fetched successfully (1) / failed (0)
+# TYPE ncscp_server_scrape_success gauge
+ncscp_server_scrape_success{account="a",servername="v1"} 0
+ncscp_server_scrape_success{account="a",servername="v2"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(want), "ncscp_server_scrape_success"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUpdateSeriesMetricSortsBackfillSamples(t *testing.T) {
+	mu := NewDefaultMetricsUpdater("a", nil, 1, true)
+	b := newSnapshotBuilder()
+	series := collector.MetricSeries{Name: collector.SeriesCpuUtilization, Values: []collector.DataPoint{
+		{Time: time.Unix(180, 0), Value: 30},
+		{Time: time.Unix(60, 0), Value: 10},
+		{Time: time.Unix(120, 0), Value: 15},
+		{Time: time.Unix(120, 0), Value: 20},
+	}}
+	mu.updateSeriesMetric(b, cpuUtilization, series, prometheus.Labels{"account": "a", "servername": "v1", "servernickname": "n1"})
+
+	e := newSnapshotExporter()
+	e.swap("a/cpu", b.build())
+	families := e.backfillFamilies()
+	if len(families) != 1 {
+		t.Fatalf("len(families) = %d; want 1", len(families))
+	}
+	var timestamps []int64
+	var values []float64
+	for _, m := range families[0].Metric {
+		timestamps = append(timestamps, m.GetTimestampMs())
+		values = append(values, m.GetGauge().GetValue())
+	}
+	if want := []int64{60000, 120000, 180000}; !slices.Equal(timestamps, want) {
+		t.Errorf("timestamps = %v; want %v", timestamps, want)
+	}
+	if want := []float64{10, 20, 30}; !slices.Equal(values, want) {
+		t.Errorf("values = %v; want %v", values, want)
+	}
+}
+
+func TestAllServersFailed(t *testing.T) {
+	decodeErr := fmt.Errorf("%w: missing series", collector.ErrUnexpectedMetricsPayload)
+	tests := []struct {
+		name      string
+		results   []collector.ServerMetrics
+		wantCause string
+	}{
+		{"no servers", nil, ""},
+		{"one succeeded", []collector.ServerMetrics{{Err: decodeErr}, {Data: &collector.MetricSeriesSet{}}}, ""},
+		{"none decoded", []collector.ServerMetrics{{Err: decodeErr}, {Err: decodeErr}}, refreshErrorCauseDecode},
+		{"none fetched", []collector.ServerMetrics{{Err: errors.New("unexpected status code")}}, refreshErrorCauseHttp},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := allServersFailed(tt.results)
+			if tt.wantCause == "" {
+				if err != nil {
+					t.Errorf("allServersFailed() error = %v; want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("allServersFailed() error = nil; want error")
+			}
+			if got := refreshErrorCause(err); got != tt.wantCause {
+				t.Errorf("refreshErrorCause() = %q; want %q", got, tt.wantCause)
+			}
+		})
+	}
+}
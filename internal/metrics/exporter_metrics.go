@@ -0,0 +1,162 @@
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kodehat/netcupscp-exporter/internal/collector"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/oauth2"
+)
+
+// Causes of refresh errors.
+const (
+	refreshErrorCauseAuth        = "auth"
+	refreshErrorCauseMaintenance = "maintenance"
+	refreshErrorCauseHttp        = "http"
+	refreshErrorCauseDecode      = "decode"
+	refreshErrorCauseConfig      = "config"
+)
+
+var refreshErrorCauses = []string{
+	refreshErrorCauseAuth,
+	refreshErrorCauseMaintenance,
+	refreshErrorCauseHttp,
+	refreshErrorCauseDecode,
+	refreshErrorCauseConfig,
+}
+
+var (
+	lastRefreshTimestamp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "last_refresh_timestamp_seconds",
+			Help:      "Timestamp of the last refresh of a data group in seconds since epoch",
+		},
+		[]string{"account", "group"})
+	lastRefreshSuccess = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "last_refresh_success",
+			Help:      "Last refresh of a data group succeeded (1) / failed (0)",
+		},
+		[]string{"account", "group"})
+	refreshDuration = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "refresh_duration_seconds",
+			Help:      "Duration of the last refresh of a data group in seconds",
+		},
+		[]string{"account", "group"})
+	refreshErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "refresh_errors_total",
+			Help:      "Total number of failed refreshes by cause (auth, maintenance, http, decode, config)",
+		},
+		[]string{"account", "group", "cause"})
+	scpRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "scp_request_duration_seconds",
+			Help:      "Latency of requests to the Netcup SCP API in seconds by endpoint and status code",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"account", "endpoint", "code"})
+)
+
+// observeRefresh records the outcome of a refresh of the given account and data group that started at the given time.
+func observeRefresh(account string, group DataGroup, start time.Time, err error) {
+	now := time.Now()
+	lastRefreshTimestamp.WithLabelValues(account, string(group)).Set(float64(now.Unix()))
+	refreshDuration.WithLabelValues(account, string(group)).Set(now.Sub(start).Seconds())
+	// Initialize all causes, so that rate() works from the very first error on.
+	for _, cause := range refreshErrorCauses {
+		refreshErrors.WithLabelValues(account, string(group), cause)
+	}
+	if err != nil {
+		lastRefreshSuccess.WithLabelValues(account, string(group)).Set(0)
+		refreshErrors.WithLabelValues(account, string(group), refreshErrorCause(err)).Inc()
+		return
+	}
+	lastRefreshSuccess.WithLabelValues(account, string(group)).Set(1)
+}
+
+func refreshErrorCause(err error) string {
+	var (
+		retrieveErr      *oauth2.RetrieveError
+		syntaxErr        *json.SyntaxError
+		unmarshalTypeErr *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.As(err, &retrieveErr):
+		return refreshErrorCauseAuth
+	case errors.Is(err, collector.ErrMaintenanceOngoing):
+		return refreshErrorCauseMaintenance
+	case errors.As(err, &syntaxErr), errors.As(err, &unmarshalTypeErr), errors.Is(err, collector.ErrUnexpectedMetricsPayload):
+		return refreshErrorCauseDecode
+	case errors.Is(err, collector.ErrUserIdUnknown):
+		return refreshErrorCauseConfig
+	default:
+		return refreshErrorCauseHttp
+	}
+}
+
+// instrumentedRoundTripper observes the latency of every request sent to the Netcup SCP API.
+type instrumentedRoundTripper struct {
+	account string
+	next    http.RoundTripper
+}
+
+// InstrumentRoundTripper wraps the given round tripper, so that the latency of every request
+// of the given account is observed. A nil round tripper uses http.DefaultTransport.
+func InstrumentRoundTripper(account string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return instrumentedRoundTripper{
+		account: account,
+		next:    next,
+	}
+}
+
+func (rt instrumentedRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := rt.next.RoundTrip(req)
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	scpRequestDuration.WithLabelValues(rt.account, normalizeEndpoint(req.URL.Path), code).Observe(time.Since(start).Seconds())
+	return resp, err
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// normalizeEndpoint strips the base path and replaces ids, mac addresses, ip addresses and uuids
+// by placeholders, so that the endpoint label has a bounded number of values.
+func normalizeEndpoint(path string) string {
+	if i := strings.Index(path, "/api/"); i >= 0 {
+		path = path[i:]
+	}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if _, err := strconv.ParseInt(segment, 10, 64); err == nil {
+			segments[i] = "{id}"
+		} else if _, err := net.ParseMAC(segment); err == nil {
+			segments[i] = "{mac}"
+		} else if _, err := netip.ParseAddr(segment); err == nil {
+			segments[i] = "{ip}"
+		} else if uuidPattern.MatchString(segment) {
+			segments[i] = "{uuid}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,27 @@
+package metrics
+
+import "testing"
+
+func TestNormalizeEndpoint(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/scp-core/api/ping", "/api/ping"},
+		{"/scp-core/api/v1/servers", "/api/v1/servers"},
+		{"/scp-core/api/v1/servers/12345", "/api/v1/servers/{id}"},
+		{"/scp-core/api/v1/servers/12345/metrics/cpu", "/api/v1/servers/{id}/metrics/cpu"},
+		{"/scp-core/api/v1/servers/12345/interfaces/52:54:00:12:34:56/firewall", "/api/v1/servers/{id}/interfaces/{mac}/firewall"},
+		{"/scp-core/api/v1/rdns/ipv4/192.0.2.10", "/api/v1/rdns/ipv4/{ip}"},
+		{"/scp-core/api/v1/rdns/ipv6/2001:db8::1", "/api/v1/rdns/ipv6/{ip}"},
+		{"/scp-core/api/v1/tasks/0b7c1f5e-3f5a-4c1e-9d43-4b2f0c1d2e3f", "/api/v1/tasks/{uuid}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := normalizeEndpoint(tt.path); got != tt.want {
+				t.Errorf("normalizeEndpoint() = %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
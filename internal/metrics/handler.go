@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// Handler serves the metrics of the given registry. If the scraper negotiates OpenMetrics, stateset
+// metrics are exposed with the stateset type, which the client library does not support itself.
+// Otherwise they are exposed as gauges, just like all other scrapers see them.
+func Handler(registry *prometheus.Registry) http.Handler {
+	fallback := promhttp.HandlerFor(registry, promhttp.HandlerOpts{EnableOpenMetrics: true})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		format := expfmt.NegotiateIncludingOpenMetrics(r.Header)
+		if format.FormatType() != expfmt.TypeOpenMetrics {
+			fallback.ServeHTTP(w, r)
+			return
+		}
+
+		families, err := registry.Gather()
+		if err != nil {
+			slog.Error("error gathering metrics", "error", err)
+			http.Error(w, "error gathering metrics: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		var buf bytes.Buffer
+		if err := writeOpenMetrics(&buf, format, families); err != nil {
+			slog.Error("error encoding metrics", "error", err)
+			http.Error(w, "error encoding metrics: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", string(format))
+		w.Header().Add("Vary", "Accept-Encoding")
+		var out io.Writer = w
+		if acceptsGzip(r.Header.Values("Accept-Encoding")) {
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			defer gz.Close()
+			out = gz
+		}
+		if _, err := buf.WriteTo(out); err != nil {
+			slog.Debug("error writing metrics", "error", err)
+		}
+	})
+}
+
+// acceptsGzip tells if the given Accept-Encoding header values allow a gzip encoded response.
+// Codings with a quality of zero are not acceptable, an explicit gzip coding takes precedence over "*".
+func acceptsGzip(headers []string) bool {
+	gzip, wildcard := -1.0, -1.0
+	for _, header := range headers {
+		for entry := range strings.SplitSeq(header, ",") {
+			coding, params, _ := strings.Cut(entry, ";")
+			quality := 1.0
+			for param := range strings.SplitSeq(params, ";") {
+				name, value, found := strings.Cut(strings.TrimSpace(param), "=")
+				if !found || !strings.EqualFold(name, "q") {
+					continue
+				}
+				q, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					q = 0
+				}
+				quality = q
+			}
+			switch coding = strings.TrimSpace(coding); {
+			case strings.EqualFold(coding, "gzip"):
+				gzip = quality
+			case coding == "*":
+				wildcard = quality
+			}
+		}
+	}
+	if gzip >= 0 {
+		return gzip > 0
+	}
+	return wildcard > 0
+}
+
+// writeOpenMetrics encodes the given metric families in the OpenMetrics text format and
+// replaces the type of all stateset metrics.
+func writeOpenMetrics(w io.Writer, format expfmt.Format, families []*dto.MetricFamily) error {
+	stateSets := map[string]bool{}
+	for _, md := range metricDescs {
+		if md.stateSet {
+			stateSets[md.name] = true
+		}
+	}
+
+	enc := expfmt.NewEncoder(w, format)
+	for _, family := range families {
+		if !stateSets[family.GetName()] {
+			if err := enc.Encode(family); err != nil {
+				return err
+			}
+			continue
+		}
+		var familyBuf bytes.Buffer
+		if err := expfmt.NewEncoder(&familyBuf, format).Encode(family); err != nil {
+			return err
+		}
+		typeLine := "# TYPE " + family.GetName() + " gauge\n"
+		stateSetLine := "# TYPE " + family.GetName() + " stateset\n"
+		if _, err := io.WriteString(w, strings.Replace(familyBuf.String(), typeLine, stateSetLine, 1)); err != nil {
+			return err
+		}
+	}
+	if closer, ok := enc.(expfmt.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
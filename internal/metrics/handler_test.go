@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kodehat/netcupscp-exporter/internal/client"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestHandlerExposesStateSets(t *testing.T) {
+	e := newSnapshotExporter()
+	b := newSnapshotBuilder()
+	setStateSet(b, serverState, serverStates, client.CRASHED, prometheus.Labels{"account": "a", "servername": "v1", "servernickname": "n1"})
+	e.swap("a/servers", b.build())
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(e)
+
+	tests := []struct {
+		name     string
+		accept   string
+		wantType string
+		wantEOF  bool
+	}{
+		{"openmetrics", "application/openmetrics-text;version=1.0.0", "# TYPE ncscp_server_state stateset", true},
+		{"text format", "text/plain;version=0.0.4", "# TYPE ncscp_server_state gauge", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			req.Header.Set("Accept", tt.accept)
+			rec := httptest.NewRecorder()
+			Handler(registry).ServeHTTP(rec, req)
+
+			body, _ := io.ReadAll(rec.Body)
+			if !strings.Contains(string(body), tt.wantType) {
+				t.Errorf("body does not contain %q:\n%s", tt.wantType, body)
+			}
+			for _, state := range serverStates {
+				value := 0
+				if state == client.CRASHED {
+					value = 1
+				}
+				sample := fmt.Sprintf(`ncscp_server_state{account="a",ncscp_server_state="%s",servername="v1",servernickname="n1"} %d`, state, value)
+				if !strings.Contains(string(body), sample) {
+					t.Errorf("body does not contain %q:\n%s", sample, body)
+				}
+			}
+			if got := strings.HasSuffix(string(body), "# EOF\n"); got != tt.wantEOF {
+				t.Errorf("body ends with EOF = %v; want %v", got, tt.wantEOF)
+			}
+		})
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []string
+		want    bool
+	}{
+		{"gzip", []string{"gzip"}, true},
+		{"among others", []string{"deflate, gzip;q=0.5"}, true},
+		{"rejected", []string{"gzip;q=0"}, false},
+		{"rejected with decimals", []string{"gzip; q=0.000, deflate"}, false},
+		{"wildcard", []string{"*"}, true},
+		{"rejected despite wildcard", []string{"*, gzip;q=0"}, false},
+		{"several headers", []string{"deflate", "GZIP"}, true},
+		{"gzip within other coding", []string{"x-gzip-like"}, false},
+		{"missing", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := acceptsGzip(tt.headers); got != tt.want {
+				t.Errorf("acceptsGzip(%q) = %v; want %v", tt.headers, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlerVariesByEncoding(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text;version=1.0.0")
+	req.Header.Set("Accept-Encoding", "gzip;q=0")
+	rec := httptest.NewRecorder()
+	Handler(registry).ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("Content-Encoding = %q; want none", got)
+	}
+	if got := rec.Header().Get("Vary"); got != "Accept-Encoding" {
+		t.Errorf("Vary = %q; want Accept-Encoding", got)
+	}
+}
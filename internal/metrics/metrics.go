@@ -16,126 +16,281 @@ var (
 			Help:      "A metric with a constant '1' value labeled by build time, commit hash, version and goversion from which netcupscp-exporter was built. Missing values are labeled as 'unknown'.",
 		},
 		[]string{"buildtime", "commithash", "version", "goversion"})
-	cpuCores = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Namespace: metricsNamespace,
-			Name:      "cpu_cores",
-			Help:      "Number of CPU cores",
-		},
-		[]string{"servername", "servernickname"})
-	memory = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Namespace: metricsNamespace,
-			Name:      "memory_bytes",
-			Help:      "Amount of memory in bytes",
-		},
-		[]string{"servername", "servernickname"})
-	monthlyTrafficIn = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Namespace: metricsNamespace,
-			Name:      "monthlytraffic_in_bytes",
-			Help:      "Monthly traffic incoming in bytes (only gigabyte-level resolution)",
-		},
-		[]string{"servername", "servernickname", "month", "year", "mac"})
-	monthlyTrafficOut = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Namespace: metricsNamespace,
-			Name:      "monthlytraffic_out_bytes",
-			Help:      "Monthly traffic outgoing in bytes",
-		},
-		[]string{"servername", "servernickname", "month", "year", "mac"})
-	monthlyTrafficTotal = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Namespace: metricsNamespace,
-			Name:      "monthlytraffic_total_bytes",
-			Help:      "Total monthly traffic in bytes",
-		},
-		[]string{"servername", "servernickname", "month", "year", "mac"})
-	serverStartTimeSeconds = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Namespace: metricsNamespace,
-			Name:      "server_start_time_seconds",
-			Help:      "Start time of the servername in seconds",
-		},
-		[]string{"servername", "servernickname"})
-	serverIpInfo = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Namespace: metricsNamespace,
-			Name:      "ip_info",
-			Help:      "Ip addresses assigned to this server",
-		},
-		[]string{"servername", "servernickname", "mac", "ip", "type"})
-	ifaceThrottled = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Namespace: metricsNamespace,
-			Name:      "interface_throttled",
-			Help:      "Interface's traffic is throttled (1) or not (0)",
-		},
-		[]string{"servername", "servernickname", "mac", "status"})
-	serverStatus = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Namespace: metricsNamespace,
-			Name:      "server_status",
-			Help:      "Online (1) / Offline (0) status",
-		},
-		[]string{"servername", "servernickname", "status"})
-	rescueActive = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Namespace: metricsNamespace,
-			Name:      "rescue_active",
-			Help:      "Rescue system active (1) / inactive (0)",
-		},
-		[]string{"servername", "servernickname", "status"})
-	rebootRecommended = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Namespace: metricsNamespace,
-			Name:      "reboot_recommended",
-			Help:      "Reboot recommended (1) / not recommended (0)",
-		},
-		[]string{"servername", "servernickname", "status"})
-	diskCapacity = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Namespace: metricsNamespace,
-			Name:      "disk_capacity_bytes",
-			Help:      "Available storage space in bytes",
-		},
-		[]string{"servername", "servernickname", "driver", "name"})
-	diskUsed = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Namespace: metricsNamespace,
-			Name:      "disk_used_bytes",
-			Help:      "Used storage space in bytes",
-		},
-		[]string{"servername", "servernickname", "driver", "name"})
-	diskOptimization = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Namespace: metricsNamespace,
-			Name:      "disk_optimization",
-			Help:      "Optimization recommended (1) / not recommended (0)",
-		},
-		[]string{"servername", "servernickname", "status"})
+	cpuCores = newMetricDesc(
+		"cpu_cores",
+		"Number of vCPUs currently assigned to the server",
+		[]string{"account", "servername", "servernickname"})
+	cpuCoresMax = newMetricDesc(
+		"cpu_cores_max",
+		"Maximum number of vCPUs the server can be scaled to",
+		[]string{"account", "servername", "servernickname"})
+	cpuSockets = newMetricDesc(
+		"cpu_sockets",
+		"Number of CPU sockets of the server",
+		[]string{"account", "servername", "servernickname"})
+	cpuCoresPerSocket = newMetricDesc(
+		"cpu_cores_per_socket",
+		"Number of CPU cores per socket of the server",
+		[]string{"account", "servername", "servernickname"})
+	memory = newMetricDesc(
+		"memory_bytes",
+		"Amount of memory currently assigned to the server in bytes",
+		[]string{"account", "servername", "servernickname"})
+	memoryMax = newMetricDesc(
+		"memory_max_bytes",
+		"Maximum amount of memory the server can be scaled to in bytes",
+		[]string{"account", "servername", "servernickname"})
+	monthlyTrafficIn = newMetricDesc(
+		"monthlytraffic_in_bytes",
+		"Monthly traffic incoming in bytes (only gigabyte-level resolution)",
+		[]string{"account", "servername", "servernickname", "month", "year", "mac"})
+	monthlyTrafficOut = newMetricDesc(
+		"monthlytraffic_out_bytes",
+		"Monthly traffic outgoing in bytes",
+		[]string{"account", "servername", "servernickname", "month", "year", "mac"})
+	monthlyTrafficTotal = newMetricDesc(
+		"monthlytraffic_total_bytes",
+		"Total monthly traffic in bytes",
+		[]string{"account", "servername", "servernickname", "month", "year", "mac"})
+	serverStartTimeSeconds = newMetricDesc(
+		"server_start_time_seconds",
+		"Start time of the servername in seconds",
+		[]string{"account", "servername", "servernickname"})
+	serverIpInfo = newMetricDesc(
+		"ip_info",
+		"Ip addresses assigned to this server",
+		[]string{"account", "servername", "servernickname", "mac", "ip", "type"})
+	ifaceVlanInfo = newMetricDesc(
+		"interface_vlan_info",
+		"VLAN the interface is attached to",
+		[]string{"account", "servername", "servernickname", "mac", "vlan_id"})
+	ifaceThrottled = newMetricDesc(
+		"interface_throttled",
+		"Interface's traffic is throttled (1) or not (0)",
+		[]string{"account", "servername", "servernickname", "mac", "status"})
+	serverStatus = newMetricDesc(
+		"server_status",
+		"Online (1) / Offline (0) status. Deprecated: use ncscp_server_state instead",
+		[]string{"account", "servername", "servernickname", "status"})
+	serverConfigInfo = newMetricDesc(
+		"server_info",
+		"Hardware and configuration of the server",
+		[]string{"account", "servername", "servernickname", "hostname", "architecture", "machine_type", "uefi", "nested_guest",
+			"cloudinit_attached", "autostart", "config_changed", "keyboard_layout", "bootorder", "template", "site", "disabled"})
+	serverConfigChanged = newMetricDesc(
+		"server_config_changed",
+		"Configuration changed and pending until the next restart (1) / unchanged (0)",
+		[]string{"account", "servername", "servernickname"})
+	serverState = newStateSetDesc(
+		"server_state",
+		"Current state of the server (1) among all possible states (0)",
+		[]string{"account", "servername", "servernickname"})
+	rescueActive = newMetricDesc(
+		"rescue_active",
+		"Rescue system active (1) / inactive (0)",
+		[]string{"account", "servername", "servernickname", "status"})
+	rebootRecommended = newMetricDesc(
+		"reboot_recommended",
+		"Reboot recommended (1) / not recommended (0)",
+		[]string{"account", "servername", "servernickname", "status"})
+	diskCapacity = newMetricDesc(
+		"disk_capacity_bytes",
+		"Available storage space in bytes",
+		[]string{"account", "servername", "servernickname", "driver", "name"})
+	diskUsed = newMetricDesc(
+		"disk_used_bytes",
+		"Used storage space in bytes",
+		[]string{"account", "servername", "servernickname", "driver", "name"})
+	diskOptimization = newMetricDesc(
+		"disk_optimization",
+		"Optimization recommended (1) / not recommended (0)",
+		[]string{"account", "servername", "servernickname", "status"})
+	storageOptimization = newStateSetDesc(
+		"storage_optimization",
+		"Required storage optimization of the server (1) among all possible optimizations (0)",
+		[]string{"account", "servername", "servernickname"})
+	osOptimization = newStateSetDesc(
+		"os_optimization",
+		"Os optimization of the server (1) among all possible optimizations (0)",
+		[]string{"account", "servername", "servernickname"})
+	osOptimizationMismatch = newMetricDesc(
+		"os_optimization_mismatch",
+		"Os optimization does not match (1) / matches (0) the operating system of the server's template",
+		[]string{"account", "servername", "servernickname", "template", "expected"})
+	maintenanceStart = newMetricDesc(
+		"maintenance_start_timestamp_seconds",
+		"Start of the current or next announced maintenance window in seconds since epoch",
+		[]string{"account"})
+	maintenanceEnd = newMetricDesc(
+		"maintenance_end_timestamp_seconds",
+		"End of the current or next announced maintenance window in seconds since epoch",
+		[]string{"account"})
+	maintenanceActive = newMetricDesc(
+		"maintenance_active",
+		"Maintenance is ongoing (1) / not ongoing (0)",
+		[]string{"account"})
+	dataStale = newMetricDesc(
+		"data_stale",
+		"Server metrics are from an earlier refresh (1) / up to date (0)",
+		[]string{"account"})
+	cpuUtilization = newMetricDesc(
+		"cpu_utilization_percent",
+		"Latest CPU utilisation in percent as measured by the hypervisor",
+		[]string{"account", "servername", "servernickname"})
+	diskReadBytes = newMetricDesc(
+		"disk_read_bytes_per_second",
+		"Latest disk read throughput in bytes per second as measured by the hypervisor",
+		[]string{"account", "servername", "servernickname", "name"})
+	diskWriteBytes = newMetricDesc(
+		"disk_write_bytes_per_second",
+		"Latest disk write throughput in bytes per second as measured by the hypervisor",
+		[]string{"account", "servername", "servernickname", "name"})
+	diskReadIops = newMetricDesc(
+		"disk_read_iops",
+		"Latest disk read operations per second as measured by the hypervisor",
+		[]string{"account", "servername", "servernickname", "name"})
+	diskWriteIops = newMetricDesc(
+		"disk_write_iops",
+		"Latest disk write operations per second as measured by the hypervisor",
+		[]string{"account", "servername", "servernickname", "name"})
+	networkReceiveBits = newMetricDesc(
+		"network_receive_bits_per_second",
+		"Latest received bits per second of an interface as measured by the hypervisor",
+		[]string{"account", "servername", "servernickname", "mac"})
+	networkTransmitBits = newMetricDesc(
+		"network_transmit_bits_per_second",
+		"Latest transmitted bits per second of an interface as measured by the hypervisor",
+		[]string{"account", "servername", "servernickname", "mac"})
+	networkReceivePackets = newMetricDesc(
+		"network_receive_packets_per_second",
+		"Latest received packets per second of an interface as measured by the hypervisor",
+		[]string{"account", "servername", "servernickname", "mac"})
+	networkTransmitPackets = newMetricDesc(
+		"network_transmit_packets_per_second",
+		"Latest transmitted packets per second of an interface as measured by the hypervisor",
+		[]string{"account", "servername", "servernickname", "mac"})
+	snapshotCount = newMetricDesc(
+		"snapshot_count",
+		"Number of snapshots of the server",
+		[]string{"account", "servername", "servernickname"})
+	snapshotAllowed = newMetricDesc(
+		"snapshot_allowed",
+		"Snapshots can be created (1) / cannot be created (0)",
+		[]string{"account", "servername", "servernickname"})
+	snapshotCreated = newMetricDesc(
+		"snapshot_created_timestamp_seconds",
+		"Creation time of the snapshot in seconds since epoch",
+		[]string{"account", "servername", "servernickname", "snapshot"})
+	snapshotExported = newMetricDesc(
+		"snapshot_exported",
+		"Snapshot exported (1) / not exported (0)",
+		[]string{"account", "servername", "servernickname", "snapshot"})
+	snapshotExportedSize = newMetricDesc(
+		"snapshot_exported_size_bytes",
+		"Size of the exported snapshot in bytes",
+		[]string{"account", "servername", "servernickname", "snapshot"})
+	tasks = newMetricDesc(
+		"tasks",
+		"Number of recent tasks of the server by name and state",
+		[]string{"account", "servername", "servernickname", "task", "state"})
+	tasksOnRollback = newMetricDesc(
+		"tasks_on_rollback",
+		"Number of recent tasks of the server by name that are rolled back",
+		[]string{"account", "servername", "servernickname", "task"})
+	taskLastDuration = newMetricDesc(
+		"task_last_duration_seconds",
+		"Duration of the last finished task of the server by name in seconds",
+		[]string{"account", "servername", "servernickname", "task"})
+	taskProgress = newMetricDesc(
+		"task_progress_percent",
+		"Progress of a running task of the server in percent",
+		[]string{"account", "servername", "servernickname", "task", "uuid"})
+	failoverIpInfo = newMetricDesc(
+		"failover_ip_info",
+		"Failover IPs of the user and the server they are routed to",
+		[]string{"account", "ip", "cidr", "type", "site", "servername"})
+	failoverIpRoutingChanges = newMetricDescWithType(
+		"failover_ip_routing_changes_total",
+		"Number of times the failover IP has been routed to another server since the exporter started",
+		[]string{"account", "ip", "type"},
+		prometheus.CounterValue)
+	firewallActive = newMetricDesc(
+		"firewall_active",
+		"Firewall of the interface active (1) / inactive (0)",
+		[]string{"account", "servername", "servernickname", "mac"})
+	firewallConsistent = newMetricDesc(
+		"firewall_consistent",
+		"Firewall rules of the interface applied (1) / not applied (0)",
+		[]string{"account", "servername", "servernickname", "mac"})
+	firewallPolicyInfo = newMetricDesc(
+		"firewall_policy_info",
+		"Firewall policies assigned to the interface",
+		[]string{"account", "servername", "servernickname", "mac", "policy", "type"})
+	firewallPolicyRules = newMetricDesc(
+		"firewall_policy_rules",
+		"Number of effective rules of a firewall policy assigned to the interface",
+		[]string{"account", "servername", "servernickname", "mac", "policy", "type"})
+	firewallImplicitRule = newMetricDesc(
+		"firewall_implicit_rule_info",
+		"Implicit rule applied to traffic of the interface not matched by any policy",
+		[]string{"account", "servername", "servernickname", "mac", "direction", "rule"})
+	vlanInfo = newMetricDesc(
+		"vlan_info",
+		"VLANs of the user",
+		[]string{"account", "vlan_id", "name", "site", "bandwidth_class"})
+	rdnsInfo = newMetricDesc(
+		"rdns_info",
+		"Reverse DNS entry of an address of the server",
+		[]string{"account", "servername", "servernickname", "ip", "type", "ptr"})
+	rdnsMatchesHostname = newMetricDesc(
+		"rdns_matches_hostname",
+		"Reverse DNS entry matches (1) / does not match (0) the hostname of the server",
+		[]string{"account", "servername", "servernickname", "ip", "type"})
+	guestAgentAvailable = newMetricDesc(
+		"guest_agent_available",
+		"QEMU guest agent available (1) / unavailable (0)",
+		[]string{"account", "servername", "servernickname"})
+	guestOsInfo = newMetricDesc(
+		"guest_os_info",
+		"Operating system reported by the QEMU guest agent",
+		[]string{"account", "servername", "servernickname", "id", "name", "version", "kernel_release", "machine"})
+	guestHostnameInfo = newMetricDesc(
+		"guest_hostname_info",
+		"Hostname reported by the QEMU guest agent",
+		[]string{"account", "servername", "servernickname", "hostname"})
+	guestFilesystemSize = newMetricDesc(
+		"guest_filesystem_size_bytes",
+		"Size of a filesystem in bytes as reported by the QEMU guest agent",
+		[]string{"account", "servername", "servernickname", "device", "mountpoint", "fstype"})
+	guestFilesystemUsed = newMetricDesc(
+		"guest_filesystem_used_bytes",
+		"Used space of a filesystem in bytes as reported by the QEMU guest agent",
+		[]string{"account", "servername", "servernickname", "device", "mountpoint", "fstype"})
+	guestIpInfo = newMetricDesc(
+		"guest_ip_info",
+		"Ip addresses configured inside the guest as reported by the QEMU guest agent",
+		[]string{"account", "servername", "servernickname", "interface", "mac", "ip", "prefix", "type"})
+	serverScrapeSuccess = newMetricDesc(
+		"server_scrape_success",
+		"Server data fetched successfully (1) / failed (0)",
+		[]string{"account", "servername"})
 )
 
+// exporter serves the latest metrics snapshot of every account.
+var exporter = newSnapshotExporter()
+
 func Load() *prometheus.Registry {
 	registry := prometheus.NewRegistry()
 	registry.MustRegister(
 		collectors.NewGoCollector(),
 		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
 		buildInfo,
-		cpuCores,
-		memory,
-		monthlyTrafficIn,
-		monthlyTrafficOut,
-		monthlyTrafficTotal,
-		serverStartTimeSeconds,
-		serverIpInfo,
-		ifaceThrottled,
-		serverStatus,
-		rescueActive,
-		rebootRecommended,
-		diskCapacity,
-		diskUsed,
-		diskOptimization,
+		exporter,
+		lastRefreshTimestamp,
+		lastRefreshSuccess,
+		refreshDuration,
+		refreshErrors,
+		scpRequestDuration,
 	)
 
 	buildInfo.With(prometheus.Labels{
@@ -147,20 +302,3 @@ func Load() *prometheus.Registry {
 
 	return registry
 }
-
-func reset() {
-	cpuCores.Reset()
-	memory.Reset()
-	monthlyTrafficIn.Reset()
-	monthlyTrafficOut.Reset()
-	monthlyTrafficTotal.Reset()
-	serverStartTimeSeconds.Reset()
-	serverIpInfo.Reset()
-	ifaceThrottled.Reset()
-	serverStatus.Reset()
-	rescueActive.Reset()
-	rebootRecommended.Reset()
-	diskCapacity.Reset()
-	diskUsed.Reset()
-	diskOptimization.Reset()
-}
@@ -1,7 +1,60 @@
 package metrics
 
-import "context"
+import (
+	"context"
+	"time"
+)
+
+// DataGroup is a part of the collected data that is refreshed on its own schedule.
+type DataGroup string
+
+const (
+	DataGroupServers     DataGroup = "servers"
+	DataGroupCpu         DataGroup = "cpu"
+	DataGroupDisk        DataGroup = "disk"
+	DataGroupNetwork     DataGroup = "network"
+	DataGroupSnapshots   DataGroup = "snapshots"
+	DataGroupTasks       DataGroup = "tasks"
+	DataGroupFailoverIps DataGroup = "failoverips"
+	DataGroupFirewalls   DataGroup = "firewalls"
+	DataGroupVlans       DataGroup = "vlans"
+	DataGroupRdns        DataGroup = "rdns"
+	DataGroupGuestAgent  DataGroup = "guestagent"
+)
+
+// DataGroups are all data groups that are refreshed periodically.
+var DataGroups = []DataGroup{
+	DataGroupServers,
+	DataGroupCpu,
+	DataGroupDisk,
+	DataGroupNetwork,
+	DataGroupSnapshots,
+	DataGroupTasks,
+	DataGroupFailoverIps,
+	DataGroupFirewalls,
+	DataGroupVlans,
+	DataGroupRdns,
+	DataGroupGuestAgent,
+}
+
+// UserDataGroups are the data groups requiring the user id.
+var UserDataGroups = []DataGroup{
+	DataGroupFailoverIps,
+	DataGroupVlans,
+}
+
+// DefaultRefreshIntervals are the minimum intervals of slow-changing data groups, which are used
+// instead of a shorter base interval unless an interval is configured for the data group itself.
+var DefaultRefreshIntervals = map[DataGroup]time.Duration{
+	DataGroupSnapshots:   5 * time.Minute,
+	DataGroupTasks:       5 * time.Minute,
+	DataGroupGuestAgent:  5 * time.Minute,
+	DataGroupFailoverIps: 15 * time.Minute,
+	DataGroupFirewalls:   15 * time.Minute,
+	DataGroupVlans:       time.Hour,
+	DataGroupRdns:        time.Hour,
+}
 
 type MetricsUpdater interface {
-	UpdateMetrics(context.Context) error
+	UpdateMetrics(context.Context, DataGroup) error
 }
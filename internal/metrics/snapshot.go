@@ -0,0 +1,163 @@
+package metrics
+
+import (
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metricDescs are all descriptors of metrics that are served using snapshots.
+var metricDescs []*metricDesc
+
+// metricDesc describes a metric together with the order of its variable labels.
+type metricDesc struct {
+	name      string
+	help      string
+	desc      *prometheus.Desc
+	labels    []string
+	valueType prometheus.ValueType
+	// stateSet marks gauges exposed as OpenMetrics stateset, having one sample of value 1 or 0 per state.
+	stateSet bool
+}
+
+func newMetricDesc(name, help string, labels []string) *metricDesc {
+	return newMetricDescWithType(name, help, labels, prometheus.GaugeValue)
+}
+
+func newMetricDescWithType(name, help string, labels []string, valueType prometheus.ValueType) *metricDesc {
+	fqName := prometheus.BuildFQName(metricsNamespace, "", name)
+	md := &metricDesc{
+		name:      fqName,
+		help:      help,
+		desc:      prometheus.NewDesc(fqName, help, labels, nil),
+		labels:    labels,
+		valueType: valueType,
+	}
+	metricDescs = append(metricDescs, md)
+	return md
+}
+
+// newStateSetDesc describes a stateset. As required by OpenMetrics, the state is given by an
+// additional label named after the metric family, which is appended to the given labels.
+func newStateSetDesc(name, help string, labels []string) *metricDesc {
+	stateLabel := prometheus.BuildFQName(metricsNamespace, "", name)
+	md := newMetricDesc(name, help, append(labels[:len(labels):len(labels)], stateLabel))
+	md.stateSet = true
+	return md
+}
+
+// snapshot is an immutable set of metrics built during a single refresh.
+type snapshot struct {
+	metrics []prometheus.Metric
+	// samples are timestamped historic values, which are only served for backfilling.
+	samples []sample
+}
+
+// sample is a single historic value of a metric.
+type sample struct {
+	md     *metricDesc
+	metric prometheus.Metric
+}
+
+// snapshotBuilder collects metrics for a new snapshot. Setting the same metric with the
+// same labels more than once keeps the last value, just like a GaugeVec would do.
+type snapshotBuilder struct {
+	metrics []prometheus.Metric
+	index   map[string]int
+	samples []sample
+}
+
+func newSnapshotBuilder() *snapshotBuilder {
+	return &snapshotBuilder{
+		index: map[string]int{},
+	}
+}
+
+// set adds the metric with the given value and labels. Labels missing in the given set are left empty.
+func (b *snapshotBuilder) set(md *metricDesc, value float64, labels prometheus.Labels) {
+	labelValues := md.labelValues(labels)
+	metric, err := prometheus.NewConstMetric(md.desc, md.valueType, value, labelValues...)
+	if err != nil {
+		slog.Warn("skipping invalid metric", "metric", md.desc.String(), "error", err)
+		return
+	}
+	key := md.desc.String() + "\xff" + strings.Join(labelValues, "\xff")
+	if i, ok := b.index[key]; ok {
+		b.metrics[i] = metric
+		return
+	}
+	b.index[key] = len(b.metrics)
+	b.metrics = append(b.metrics, metric)
+}
+
+// addSample adds a historic value of the metric, which is only served for backfilling.
+func (b *snapshotBuilder) addSample(md *metricDesc, value float64, t time.Time, labels prometheus.Labels) {
+	metric, err := prometheus.NewConstMetric(md.desc, md.valueType, value, md.labelValues(labels)...)
+	if err != nil {
+		slog.Warn("skipping invalid sample", "metric", md.desc.String(), "error", err)
+		return
+	}
+	b.samples = append(b.samples, sample{md: md, metric: prometheus.NewMetricWithTimestamp(t, metric)})
+}
+
+func (b *snapshotBuilder) build() *snapshot {
+	return &snapshot{
+		metrics: b.metrics,
+		samples: b.samples,
+	}
+}
+
+// labelValues returns the values of the given labels in the order of the metric's labels.
+func (md *metricDesc) labelValues(labels prometheus.Labels) []string {
+	labelValues := make([]string, len(md.labels))
+	for i, label := range md.labels {
+		labelValues[i] = labels[label]
+	}
+	return labelValues
+}
+
+// snapshotExporter is a prometheus.Collector serving the latest snapshot of every account.
+// Snapshots are swapped atomically, so that every scrape sees one consistent generation of metrics.
+type snapshotExporter struct {
+	mu        sync.Mutex // serializes swaps
+	snapshots atomic.Pointer[map[string]*snapshot]
+}
+
+var _ prometheus.Collector = &snapshotExporter{}
+
+func newSnapshotExporter() *snapshotExporter {
+	e := &snapshotExporter{}
+	e.snapshots.Store(&map[string]*snapshot{})
+	return e
+}
+
+// swap replaces the snapshot stored under the given key.
+func (e *snapshotExporter) swap(key string, s *snapshot) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	current := *e.snapshots.Load()
+	next := make(map[string]*snapshot, len(current)+1)
+	for k, v := range current {
+		next[k] = v
+	}
+	next[key] = s
+	e.snapshots.Store(&next)
+}
+
+func (e *snapshotExporter) Describe(ch chan<- *prometheus.Desc) {
+	for _, md := range metricDescs {
+		ch <- md.desc
+	}
+}
+
+func (e *snapshotExporter) Collect(ch chan<- prometheus.Metric) {
+	for _, s := range *e.snapshots.Load() {
+		for _, metric := range s.metrics {
+			ch <- metric
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSnapshotBuilderKeepsLastValue(t *testing.T) {
+	b := newSnapshotBuilder()
+	labels := prometheus.Labels{"account": "a", "servername": "v1", "servernickname": "n1"}
+	b.set(cpuCores, 2, labels)
+	b.set(cpuCores, 4, labels)
+
+	s := b.build()
+	if got := len(s.metrics); got != 1 {
+		t.Fatalf("len(metrics) = %d; want 1", got)
+	}
+}
+
+func TestSnapshotExporterSwapsPerKey(t *testing.T) {
+	e := newSnapshotExporter()
+
+	first := newSnapshotBuilder()
+	first.set(cpuCores, 2, prometheus.Labels{"account": "a", "servername": "v1", "servernickname": "n1"})
+	first.set(cpuCores, 4, prometheus.Labels{"account": "a", "servername": "v2", "servernickname": "n2"})
+	e.swap("a", first.build())
+
+	second := newSnapshotBuilder()
+	second.set(cpuCores, 8, prometheus.Labels{"account": "b", "servername": "v3", "servernickname": "n3"})
+	e.swap("b", second.build())
+
+	// Replacing the snapshot of account "a" must leave account "b" untouched.
+	third := newSnapshotBuilder()
+	third.set(cpuCores, 6, prometheus.Labels{"account": "a", "servername": "v1", "servernickname": "n1"})
+	e.swap("a", third.build())
+
+	want := `
+# HELP ncscp_cpu_cores Number of vCPUs currently assigned to the server
+# TYPE ncscp_cpu_cores gauge
+ncscp_cpu_cores{account="a",servername="v1",servernickname="n1"} 6
+ncscp_cpu_cores{account="b",servername="v3",servernickname="n3"} 8
+`
+	if err := testutil.CollectAndCompare(e, strings.NewReader(want), "ncscp_cpu_cores"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBackfillFamiliesKeepsAllSamples(t *testing.T) {
+	e := newSnapshotExporter()
+
+	b := newSnapshotBuilder()
+	labels := prometheus.Labels{"account": "a", "servername": "v1", "servernickname": "n1"}
+	b.addSample(cpuUtilization, 10, time.Unix(60, 0), labels)
+	b.addSample(cpuUtilization, 20, time.Unix(120, 0), labels)
+	e.swap("a/cpu", b.build())
+
+	families := e.backfillFamilies()
+	if got := len(families); got != 1 {
+		t.Fatalf("len(families) = %d; want 1", got)
+	}
+	if got := len(families[0].Metric); got != 2 {
+		t.Fatalf("len(metrics) = %d; want 2", got)
+	}
+	if got := families[0].Metric[1].GetTimestampMs(); got != 120000 {
+		t.Errorf("GetTimestampMs() = %d; want 120000", got)
+	}
+}
@@ -1,5 +1,7 @@
 package metrics
 
+import "github.com/kodehat/netcupscp-exporter/internal/client"
+
 type ServerStatus int
 
 const (
@@ -16,6 +18,37 @@ func (ss ServerStatus) String() string {
 	return serverStatusName[ss]
 }
 
+// serverStates are all states of the server state stateset.
+var serverStates = []client.ServerState{
+	client.BLOCKED,
+	client.CRASHED,
+	client.DISKSNAPSHOT,
+	client.NOSTATE,
+	client.PAUSED,
+	client.PMSUSPENDED,
+	client.RUNNING,
+	client.SHUTDOWN,
+	client.SHUTOFF,
+}
+
+// storageOptimizations are all states of the storage optimization stateset.
+var storageOptimizations = []client.StorageOptimization{
+	client.COMPAT,
+	client.FAST,
+	client.INCONSISTENT,
+	client.NO,
+	client.SLOW,
+}
+
+// osOptimizations are all states of the os optimization stateset.
+var osOptimizations = []client.OsOptimization{
+	client.BSD,
+	client.LINUX,
+	client.LINUXLEGACY,
+	client.UNKNOWN,
+	client.WINDOWS,
+}
+
 type RescueSystemStatus int
 
 const (
@@ -79,3 +112,66 @@ var interfaceThrottledStatusName = map[InterfaceThrottledStatus]string{
 func (its InterfaceThrottledStatus) String() string {
 	return interfaceThrottledStatusName[its]
 }
+
+type MaintenanceStatus int
+
+const (
+	MAINTENANCE_INACTIVE MaintenanceStatus = iota
+	MAINTENANCE_ACTIVE
+)
+
+type SnapshotAllowedStatus int
+
+const (
+	SNAPSHOT_NOT_ALLOWED SnapshotAllowedStatus = iota
+	SNAPSHOT_ALLOWED
+)
+
+type SnapshotExportedStatus int
+
+const (
+	SNAPSHOT_NOT_EXPORTED SnapshotExportedStatus = iota
+	SNAPSHOT_EXPORTED
+)
+
+type FirewallActiveStatus int
+
+const (
+	FIREWALL_INACTIVE FirewallActiveStatus = iota
+	FIREWALL_ACTIVE
+)
+
+type FirewallConsistentStatus int
+
+const (
+	FIREWALL_INCONSISTENT FirewallConsistentStatus = iota
+	FIREWALL_CONSISTENT
+)
+
+type RdnsMatchStatus int
+
+const (
+	RDNS_MISMATCH RdnsMatchStatus = iota
+	RDNS_MATCH
+)
+
+type GuestAgentStatus int
+
+const (
+	GUEST_AGENT_UNAVAILABLE GuestAgentStatus = iota
+	GUEST_AGENT_AVAILABLE
+)
+
+type OsOptimizationMismatchStatus int
+
+const (
+	OS_OPTIMIZATION_MATCH OsOptimizationMismatchStatus = iota
+	OS_OPTIMIZATION_MISMATCH
+)
+
+type ConfigChangedStatus int
+
+const (
+	CONFIG_UNCHANGED ConfigChangedStatus = iota
+	CONFIG_CHANGED
+)
@@ -3,49 +3,83 @@ package refresher
 import (
 	"context"
 	"log/slog"
+	"math/rand/v2"
+	"sync"
 	"time"
 
 	"github.com/kodehat/netcupscp-exporter/internal/metrics"
+	"github.com/kodehat/netcupscp-exporter/internal/retry"
 )
 
+// Schedule defines how often a data group is refreshed.
+type Schedule struct {
+	Group    metrics.DataGroup
+	Interval time.Duration
+}
+
 type DefaultRefresher struct {
-	metricsUpdater  metrics.MetricsUpdater
-	refreshInterval time.Duration
+	metricsUpdater metrics.MetricsUpdater
+	schedules      []Schedule
+	jitter         time.Duration
+	retryBudget    int
 }
 
 var _ Refresher = DefaultRefresher{}
 
-func NewDefaultRefresher(metricsUpdater metrics.MetricsUpdater, refreshInterval time.Duration) DefaultRefresher {
+// NewDefaultRefresher creates a new DefaultRefresher that refreshes every data group on its own schedule.
+// Every refresh is delayed by a random duration up to the given jitter, so that several exporters
+// do not hit the Netcup SCP API at the same time. All requests of a single refresh share the given retry budget.
+func NewDefaultRefresher(metricsUpdater metrics.MetricsUpdater, schedules []Schedule, jitter time.Duration, retryBudget int) DefaultRefresher {
 	return DefaultRefresher{
-		metricsUpdater:  metricsUpdater,
-		refreshInterval: refreshInterval,
+		metricsUpdater: metricsUpdater,
+		schedules:      schedules,
+		jitter:         jitter,
+		retryBudget:    retryBudget,
 	}
 }
 
-func (dr DefaultRefresher) refresh(ctx context.Context) error {
-	err := dr.metricsUpdater.UpdateMetrics(ctx)
+func (dr DefaultRefresher) refresh(ctx context.Context, schedule Schedule) error {
+	err := dr.metricsUpdater.UpdateMetrics(retry.WithBudget(ctx, dr.retryBudget), schedule.Group)
 	if err != nil {
-		slog.Error("error while updating metrics", "error", err)
+		slog.Error("error while updating metrics", "group", schedule.Group, "error", err)
 		return err
 	}
-	slog.Debug("metrics have been updated successfully", "next_update", time.Now().Add(dr.refreshInterval))
+	slog.Debug("metrics have been updated successfully", "group", schedule.Group, "next_update", time.Now().Add(schedule.Interval))
 	return nil
 }
 
-func (dr DefaultRefresher) StartRefreshMetricsPeriodically(ctx context.Context) {
-	ticker := time.NewTicker(dr.refreshInterval)
-	defer ticker.Stop()
-	slog.Info("starting periodic metrics update", "interval", dr.refreshInterval.String())
-	dr.refresh(ctx) // Run once immediately.
+// randomJitter returns a random duration between zero and the configured jitter.
+func (dr DefaultRefresher) randomJitter() time.Duration {
+	if dr.jitter <= 0 {
+		return 0
+	}
+	return rand.N(dr.jitter)
+}
+
+func (dr DefaultRefresher) refreshPeriodically(ctx context.Context, schedule Schedule) {
+	slog.Info("starting periodic metrics update", "group", schedule.Group, "interval", schedule.Interval.String())
+	timer := time.NewTimer(dr.randomJitter()) // Run once almost immediately.
+	defer timer.Stop()
 	for {
 		select {
-		case <-ticker.C:
-			if err := dr.refresh(ctx); err != nil {
-				slog.Warn("metrics update error occurred during metrics refresh", "error", err)
+		case <-timer.C:
+			if err := dr.refresh(ctx, schedule); err != nil {
+				slog.Warn("metrics update error occurred during metrics refresh", "group", schedule.Group, "error", err)
 			}
+			timer.Reset(schedule.Interval + dr.randomJitter())
 		case <-ctx.Done():
-			slog.Debug("stopping updating metrics")
+			slog.Debug("stopping updating metrics", "group", schedule.Group)
 			return
 		}
 	}
 }
+
+func (dr DefaultRefresher) StartRefreshMetricsPeriodically(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, schedule := range dr.schedules {
+		wg.Go(func() {
+			dr.refreshPeriodically(ctx, schedule)
+		})
+	}
+	wg.Wait()
+}
@@ -0,0 +1,31 @@
+package retry
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+type budgetContextKey struct{}
+
+// budget limits the number of retries within a single refresh cycle.
+type budget struct {
+	remaining atomic.Int64
+}
+
+// WithBudget returns a context that allows the given number of retries in total
+// for all requests sent using it. Without budget, retries are only limited per request.
+func WithBudget(ctx context.Context, retries int) context.Context {
+	b := &budget{}
+	b.remaining.Store(int64(retries))
+	return context.WithValue(ctx, budgetContextKey{}, b)
+}
+
+// takeBudget consumes a single retry from the budget of the given context and
+// reports whether a retry was left.
+func takeBudget(ctx context.Context) bool {
+	b, ok := ctx.Value(budgetContextKey{}).(*budget)
+	if !ok {
+		return true
+	}
+	return b.remaining.Add(-1) >= 0
+}
@@ -0,0 +1,122 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kodehat/netcupscp-exporter/internal/client"
+	"golang.org/x/oauth2"
+)
+
+// Doer retries idempotent requests that failed because of network errors or
+// server side errors using exponential backoff with jitter. Retry-After headers
+// of 429 and 503 responses are honored.
+type Doer struct {
+	next       client.HttpRequestDoer
+	maxRetries int
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+}
+
+var _ client.HttpRequestDoer = &Doer{}
+
+// NewDoer creates a new Doer retrying every request up to the given number of times.
+// Backoff starts at the given base delay and never exceeds the given max delay.
+// Responses asking to retry after more than the max delay are not retried at all.
+func NewDoer(next client.HttpRequestDoer, maxRetries int, baseDelay, maxDelay time.Duration) *Doer {
+	return &Doer{
+		next:       next,
+		maxRetries: maxRetries,
+		baseDelay:  baseDelay,
+		maxDelay:   maxDelay,
+	}
+}
+
+func (d *Doer) Do(req *http.Request) (*http.Response, error) {
+	if !isIdempotent(req) {
+		return d.next.Do(req)
+	}
+	for attempt := 0; ; attempt++ {
+		resp, err := d.next.Do(req)
+		if attempt >= d.maxRetries || !isRetryable(resp, err) {
+			return resp, err
+		}
+		delay, ok := d.delay(attempt, resp)
+		if !ok || !takeBudget(req.Context()) {
+			return resp, err
+		}
+		slog.Debug("retrying request", "url", req.URL.String(), "attempt", attempt+1, "delay", delay.String(), "status", status(resp), "error", err)
+		if resp != nil {
+			// Drain the body, so that the connection can be reused.
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// delay returns how long to wait before the next attempt and whether it should be made at all.
+func (d *Doer) delay(attempt int, resp *http.Response) (time.Duration, bool) {
+	if resp != nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) {
+		if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			return retryAfter, retryAfter <= d.maxDelay
+		}
+	}
+	backoff := d.baseDelay << attempt
+	if backoff <= 0 || backoff > d.maxDelay {
+		backoff = d.maxDelay
+	}
+	// Use full jitter, so that concurrent requests do not retry in lockstep.
+	return rand.N(backoff + 1), true
+}
+
+func isIdempotent(req *http.Request) bool {
+	return (req.Method == http.MethodGet || req.Method == http.MethodHead) && req.Body == nil
+}
+
+func isRetryable(resp *http.Response, err error) bool {
+	if err != nil {
+		// Neither a cancelled request nor a rejected refresh token gets better by retrying.
+		var retrieveErr *oauth2.RetrieveError
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) && !errors.As(err, &retrieveErr)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// parseRetryAfter parses the Retry-After header, which is either a number of seconds or a http date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+func status(resp *http.Response) string {
+	if resp == nil {
+		return ""
+	}
+	return resp.Status
+}
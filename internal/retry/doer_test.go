@@ -0,0 +1,184 @@
+package retry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestServer returns a server that responds with the given status codes in order
+// and with 200 afterwards, together with the number of received requests.
+func newTestServer(t *testing.T, header http.Header, statusCodes ...int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := int(requests.Add(1)) - 1
+		for k, v := range header {
+			w.Header()[k] = v
+		}
+		if i < len(statusCodes) {
+			w.WriteHeader(statusCodes[i])
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func doRequest(t *testing.T, ctx context.Context, doer *Doer, method, url string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+	resp, err := doer.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v; want nil", err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestDoerRetriesServerErrors(t *testing.T) {
+	server, requests := newTestServer(t, nil, http.StatusBadGateway, http.StatusGatewayTimeout)
+	doer := NewDoer(server.Client(), 3, time.Millisecond, 10*time.Millisecond)
+
+	resp := doRequest(t, context.Background(), doer, http.MethodGet, server.URL)
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d; want %d", resp.StatusCode, http.StatusOK)
+	}
+	if got := requests.Load(); got != 3 {
+		t.Errorf("requests = %d; want 3", got)
+	}
+}
+
+func TestDoerGivesUpAfterMaxRetries(t *testing.T) {
+	server, requests := newTestServer(t, nil, http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway)
+	doer := NewDoer(server.Client(), 1, time.Millisecond, 10*time.Millisecond)
+
+	resp := doRequest(t, context.Background(), doer, http.MethodGet, server.URL)
+
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("status = %d; want %d", resp.StatusCode, http.StatusBadGateway)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("requests = %d; want 2", got)
+	}
+}
+
+func TestDoerDoesNotRetryClientErrors(t *testing.T) {
+	server, requests := newTestServer(t, nil, http.StatusNotFound)
+	doer := NewDoer(server.Client(), 3, time.Millisecond, 10*time.Millisecond)
+
+	resp := doRequest(t, context.Background(), doer, http.MethodGet, server.URL)
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status = %d; want %d", resp.StatusCode, http.StatusNotFound)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("requests = %d; want 1", got)
+	}
+}
+
+func TestDoerDoesNotRetryNonIdempotentRequests(t *testing.T) {
+	server, requests := newTestServer(t, nil, http.StatusBadGateway)
+	doer := NewDoer(server.Client(), 3, time.Millisecond, 10*time.Millisecond)
+
+	req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader("{}"))
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+	resp, err := doer.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v; want nil", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("status = %d; want %d", resp.StatusCode, http.StatusBadGateway)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("requests = %d; want 1", got)
+	}
+}
+
+func TestDoerHonorsRetryAfter(t *testing.T) {
+	server, requests := newTestServer(t, http.Header{"Retry-After": []string{"1"}}, http.StatusTooManyRequests)
+	doer := NewDoer(server.Client(), 3, time.Millisecond, 2*time.Second)
+
+	start := time.Now()
+	resp := doRequest(t, context.Background(), doer, http.MethodGet, server.URL)
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d; want %d", resp.StatusCode, http.StatusOK)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("requests = %d; want 2", got)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("elapsed = %v; want at least 1s", elapsed)
+	}
+}
+
+func TestDoerDoesNotWaitForRetryAfterExceedingMaxDelay(t *testing.T) {
+	server, requests := newTestServer(t, http.Header{"Retry-After": []string{"3600"}}, http.StatusServiceUnavailable)
+	doer := NewDoer(server.Client(), 3, time.Millisecond, 10*time.Millisecond)
+
+	resp := doRequest(t, context.Background(), doer, http.MethodGet, server.URL)
+
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("status = %d; want %d", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("requests = %d; want 1", got)
+	}
+}
+
+func TestDoerRespectsBudget(t *testing.T) {
+	server, requests := newTestServer(t, nil, http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway)
+	doer := NewDoer(server.Client(), 3, time.Millisecond, 10*time.Millisecond)
+	ctx := WithBudget(context.Background(), 2)
+
+	// The first request uses up the whole budget, the second one is not retried at all.
+	first := doRequest(t, ctx, doer, http.MethodGet, server.URL)
+	second := doRequest(t, ctx, doer, http.MethodGet, server.URL)
+
+	if first.StatusCode != http.StatusBadGateway || second.StatusCode != http.StatusBadGateway {
+		t.Errorf("status = %d, %d; want %d, %d", first.StatusCode, second.StatusCode, http.StatusBadGateway, http.StatusBadGateway)
+	}
+	if got := requests.Load(); got != 4 {
+		t.Errorf("requests = %d; want 4", got)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		wantOk bool
+	}{
+		{"empty", "", 0, false},
+		{"seconds", "120", 2 * time.Minute, true},
+		{"http date", "Wed, 01 Jan 2025 12:00:30 GMT", 30 * time.Second, true},
+		{"http date in the past", "Wed, 01 Jan 2025 11:00:00 GMT", 0, true},
+		{"invalid", "soon", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseRetryAfter(tt.value, now)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("parseRetryAfter() = %v, %v; want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
@@ -2,25 +2,34 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net"
 	"net/http"
+	"net/netip"
 	"os"
 	"os/signal"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/kodehat/netcupscp-exporter/internal/authenticator"
 	"github.com/kodehat/netcupscp-exporter/internal/collector"
+	"github.com/kodehat/netcupscp-exporter/internal/config"
 	"github.com/kodehat/netcupscp-exporter/internal/flags"
+	"github.com/kodehat/netcupscp-exporter/internal/login"
+	"github.com/kodehat/netcupscp-exporter/internal/logshipper"
 	"github.com/kodehat/netcupscp-exporter/internal/metrics"
 	"github.com/kodehat/netcupscp-exporter/internal/refresher"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/kodehat/netcupscp-exporter/internal/retry"
 )
 
-var metricRefreshInterval = 30 * time.Second
+var (
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 10 * time.Second
+)
 
 func main() {
 	ctx := context.Background()
@@ -32,41 +41,67 @@ func main() {
 	}
 }
 
-func run(ctx context.Context, flags flags.Flags, _ io.Reader, stdout, _ io.Writer) error {
+func run(ctx context.Context, flags flags.Flags, _ io.Reader, stdout, stderr io.Writer) error {
 	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt)
 	defer cancel()
 
 	logger := slog.New(flags.GetLogHandler(stdout))
 	slog.SetDefault(logger)
 
-	defaultAuthenticator := authenticator.NewDefaultAuthenticator(flags.RefreshToken)
-	authResult, err := defaultAuthenticator.Authenticate(ctx)
+	accounts, err := loadAccounts(flags)
 	if err != nil {
-		logger.Error("error during authentication", "error", err)
+		logger.Error("error loading accounts", "error", err)
 		return err
 	}
-	if authResult.IsNewDevice {
-		logger.Warn("first-time setup: obtained new refresh token, please store it for future use", "refresh_token", authResult.RefreshToken)
-		logger.Info("the application will now exit, please restart it with the new refresh token")
-		return nil
+	schedules, err := buildSchedules(logger, flags)
+	if err != nil {
+		logger.Error("error building refresh schedules", "error", err)
+		return err
 	}
-
-	registry := metrics.Load()
-
-	serverCollector, err := collector.NewDefaultServerCollector(defaultAuthenticator)
+	rdnsIpv6Addresses, err := flags.GetRdnsIpv6Addresses()
 	if err != nil {
-		logger.Error("error creating server collector", "error", err)
+		logger.Error("error parsing rdns ipv6 addresses", "error", err)
 		return err
 	}
-	metricsUpdater := metrics.NewDefaultMetricsUpdater(serverCollector)
-	refresher := refresher.NewDefaultRefresher(metricsUpdater, metricRefreshInterval)
+	logSink, closeLogSink, err := newLogSink(flags, stderr)
+	if err != nil {
+		logger.Error("error creating log shipper sink", "error", err)
+		return err
+	}
+	defer closeLogSink()
+	logCursors, err := logshipper.NewCursors(flags.CursorFile)
+	if err != nil {
+		logger.Error("error loading log shipper cursors", "error", err)
+		return err
+	}
+	options := accountOptions{
+		schedules:         schedules,
+		rdnsIpv6Addresses: rdnsIpv6Addresses,
+		logSink:           logSink,
+		logCursors:        logCursors,
+	}
+
+	loginAccounts := make([]login.Account, len(accounts))
+	authenticators := make([]*authenticator.DefaultAuthenticator, len(accounts))
+	for i, account := range accounts {
+		var tokenStore authenticator.TokenStore
+		if account.TokenFile != "" {
+			tokenStore = authenticator.NewFileTokenStore(account.TokenFile)
+		}
+		authenticators[i] = authenticator.NewDefaultAuthenticator(account.RefreshToken, tokenStore)
+		loginAccounts[i] = login.Account{Name: account.Name, Authenticator: authenticators[i]}
+	}
 
-	// Start periodic metrics refresh (including refreshing authentication) in a separate goroutine.
-	go refresher.StartRefreshMetricsPeriodically(ctx)
+	registry := metrics.Load()
 
-	// Create http server for Prometheus metrics.
+	// Create http server for Prometheus metrics. It is started before authenticating,
+	// so that a pending device authorization can be completed using the login page.
 	mux := http.NewServeMux()
-	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{EnableOpenMetrics: true}))
+	mux.Handle("/metrics", metrics.Handler(registry))
+	if flags.MetricsBackfill {
+		mux.Handle("/metrics/backfill", metrics.BackfillHandler())
+	}
+	mux.Handle("/login", login.NewHandler(loginAccounts))
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		http.NotFound(w, r)
 	})
@@ -81,16 +116,177 @@ func run(ctx context.Context, flags flags.Flags, _ io.Reader, stdout, _ io.Write
 			logger.Error("error listening and serving", "error", err)
 		}
 	}()
-	var wg sync.WaitGroup
-	wg.Go(func() {
-		<-ctx.Done()
+	defer func() {
 		// Use a new context for shutdown.
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 		if err := httpServer.Shutdown(shutdownCtx); err != nil {
 			logger.Error("error shutting down http server", "error", err)
 		}
-	})
+	}()
+
+	// Run every account on its own, so that one failing account does not affect the others.
+	var wg sync.WaitGroup
+	errs := make([]error, len(accounts))
+	for i, account := range accounts {
+		wg.Go(func() {
+			errs[i] = runAccount(ctx, logger.With("account", account.Name), flags, account, authenticators[i], options)
+		})
+	}
+	wg.Wait()
+
+	// Only fail if no account could be started at all.
+	for _, err := range errs {
+		if err == nil {
+			return nil
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// accountOptions are shared by all accounts.
+type accountOptions struct {
+	schedules         []refresher.Schedule
+	rdnsIpv6Addresses []netip.Addr
+	// logSink is nil if log shipping is disabled.
+	logSink    logshipper.Sink
+	logCursors *logshipper.Cursors
+}
+
+// runAccount authenticates the given account and refreshes its metrics until the context is done.
+func runAccount(ctx context.Context, logger *slog.Logger, flags flags.Flags, account config.Account, defaultAuthenticator *authenticator.DefaultAuthenticator, options accountOptions) error {
+	authResult, err := defaultAuthenticator.Authenticate(ctx)
+	if err != nil {
+		logger.Error("error during authentication", "error", err)
+		return err
+	}
+	if authResult.IsNewDevice && account.TokenFile != "" {
+		logger.Info("first-time setup: obtained new refresh token and saved it to token file", "token_file", account.TokenFile)
+	} else if authResult.IsNewDevice {
+		logger.Warn("first-time setup: obtained new refresh token, please store it for future use or configure a token file", "refresh_token", authResult.RefreshToken)
+	}
+
+	// Observe every request sent to the Netcup SCP API. The round tripper sits below the authentication,
+	// so that refreshing the access token does not count as API latency.
+	instrumentedClient := defaultAuthenticator.GetAuthenticatedClientWithBase(metrics.InstrumentRoundTripper(account.Name, nil))
+	retryingDoer := retry.NewDoer(instrumentedClient, flags.RetryMax, retryBaseDelay, retryMaxDelay)
+	userId := userIdFunc(logger, account, defaultAuthenticator)
+	serverCollector, err := collector.NewDefaultServerCollector(retryingDoer, flags.Workers, userId, options.rdnsIpv6Addresses)
+	if err != nil {
+		logger.Error("error creating server collector", "error", err)
+		return err
+	}
+	schedules := options.schedules
+	if userId == nil {
+		// Data groups requiring the user id would only fail on every refresh.
+		schedules = slices.DeleteFunc(slices.Clone(schedules), func(schedule refresher.Schedule) bool {
+			return slices.Contains(metrics.UserDataGroups, schedule.Group)
+		})
+	}
+	metricsUpdater := metrics.NewDefaultMetricsUpdater(account.Name, serverCollector, int32(flags.MetricsHours), flags.MetricsBackfill)
+	refresher := refresher.NewDefaultRefresher(metricsUpdater, schedules, flags.RefreshJitter, flags.RetryBudget)
+
+	// Ship logs alongside refreshing metrics, if enabled.
+	var wg sync.WaitGroup
+	if options.logSink != nil {
+		logShipper := logshipper.NewDefaultLogShipper(account.Name, serverCollector, options.logSink, options.logCursors, flags.LogInterval, flags.RefreshJitter)
+		wg.Go(func() {
+			logShipper.StartShippingPeriodically(ctx)
+		})
+	}
+
+	// Refresh metrics periodically (including refreshing authentication) until the context is done.
+	refresher.StartRefreshMetricsPeriodically(ctx)
 	wg.Wait()
 	return nil
 }
+
+// newLogSink returns the sink configured for log shipping, which is nil if log shipping is disabled.
+// The returned function closes the log file, if any. Entries written to "-" go to stderr, so that
+// they are kept apart from the exporter's own log output on stdout.
+func newLogSink(flags flags.Flags, stderr io.Writer) (logshipper.Sink, func() error, error) {
+	noop := func() error { return nil }
+	switch {
+	case flags.LokiUrl != "" && flags.LogFile != "":
+		return nil, noop, errors.New("log shipper can either push to loki or write to a file")
+	case flags.LokiUrl != "":
+		return logshipper.NewLokiSink(flags.LokiUrl, &http.Client{Timeout: 10 * time.Second}), noop, nil
+	case flags.LogFile == "-":
+		return logshipper.NewJsonLinesSink(stderr), noop, nil
+	case flags.LogFile != "":
+		file, err := os.OpenFile(flags.LogFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+		if err != nil {
+			return nil, noop, err
+		}
+		return logshipper.NewJsonLinesSink(file), file.Close, nil
+	default:
+		return nil, noop, nil
+	}
+}
+
+// userIdFunc returns the configured user id of the account or, if none is configured, takes it from the
+// access token, which is retried by the collector until it succeeds. Nil is returned if the access token
+// carries no user id, which disables user specific data.
+func userIdFunc(logger *slog.Logger, account config.Account, defaultAuthenticator *authenticator.DefaultAuthenticator) collector.UserIdFunc {
+	if account.UserId != 0 {
+		return func() (int32, error) { return account.UserId, nil }
+	}
+	userId, err := defaultAuthenticator.UserId()
+	if errors.Is(err, authenticator.ErrNoUserIdInToken) {
+		logger.Warn("unable to determine user id from access token, configure it to collect failover IPs, VLANs and account logs", "error", err)
+		return nil
+	} else if err != nil {
+		logger.Warn("unable to determine user id from access token yet, retrying on next use", "error", err)
+	} else {
+		logger.Debug("determined user id from access token", "user_id", userId)
+	}
+	return defaultAuthenticator.UserId
+}
+
+// loadAccounts returns the accounts of the config file or, if no config file is set,
+// a single default account configured by flags.
+func loadAccounts(flags flags.Flags) ([]config.Account, error) {
+	if flags.ConfigFile == "" {
+		return []config.Account{{
+			Name:         config.DefaultAccountName,
+			RefreshToken: flags.RefreshToken,
+			TokenFile:    flags.TokenFile,
+			UserId:       int32(flags.UserId),
+		}}, nil
+	}
+	cfg, err := config.Load(flags.ConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.Accounts, nil
+}
+
+// buildSchedules returns a schedule for every data group using the base refresh interval, or the
+// default interval of slow-changing data groups if it is longer, unless an interval is configured
+// for the data group itself. Data groups configured with an interval of zero are disabled.
+func buildSchedules(logger *slog.Logger, flags flags.Flags) ([]refresher.Schedule, error) {
+	if flags.RefreshInterval <= 0 {
+		return nil, errors.New("refresh interval must be positive")
+	}
+	intervals, err := flags.GetRefreshIntervals()
+	if err != nil {
+		return nil, err
+	}
+	var schedules []refresher.Schedule
+	for _, group := range metrics.DataGroups {
+		interval := max(flags.RefreshInterval, metrics.DefaultRefreshIntervals[group])
+		if configured, ok := intervals[string(group)]; ok {
+			interval = configured
+			delete(intervals, string(group))
+		}
+		if interval == 0 {
+			logger.Info("data group disabled", "group", group)
+			continue
+		}
+		schedules = append(schedules, refresher.Schedule{Group: group, Interval: interval})
+	}
+	for group := range intervals {
+		return nil, fmt.Errorf("refresh interval configured for unknown data group: %s", group)
+	}
+	return schedules, nil
+}